@@ -0,0 +1,188 @@
+// Package busterm fetches bus departures from ACIS Connect sites, for programs that want
+// busterm's boards without running the command.
+//
+//	client := busterm.NewClient(busterm.WithTimeout(10*time.Second), busterm.WithRetries(2))
+//	departures, err := client.Departures(ctx, "45010687")
+//
+// A Client is safe to use from several goroutines and reuses its connections, so make one and
+// keep it. Failures wrap the kinds in the bus package, to check with errors.Is.
+package busterm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/return/busterm/pkg/acis"
+	"github.com/return/busterm/pkg/bus"
+)
+
+// Departure is a bus leaving a stop.
+type Departure = bus.Bus
+
+// maxBackoff is the longest a Client waits between retries.
+const maxBackoff = 30 * time.Second
+
+// Client fetches departures, configured by Options.
+type Client struct {
+	baseURL   string
+	userAgent string
+	http      *http.Client
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
+	cache     Cache
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL fetches from another ACIS Connect site's departure page.
+// (http://cambridgeshire.acisconnect.com/Text/WebDisplay.aspx)
+func WithBaseURL(url string) Option {
+	return func(c *Client) { c.baseURL = url }
+}
+
+// WithTimeout limits how long each fetch may take. (default 10s)
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithUserAgent sends another user agent. The site turns away ones it doesn't recognise.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetries tries failed fetches again this many times, when trying again may work (the site
+// is down or rate limited), waiting half a second and then twice as long each time, up to 30 seconds. (default 0)
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retries = n }
+}
+
+// WithHTTPClient makes the requests with an HTTP client of your own, such as one with a proxy.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// WithCache keeps boards in a cache, so a stop asked for again soon isn't fetched again.
+func WithCache(cache Cache) Option {
+	return func(c *Client) { c.cache = cache }
+}
+
+// NewClient makes a Client fetching from West Yorkshire's site, changed by the options.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:   acis.DefaultURL,
+		userAgent: acis.DefaultUserAgent,
+		http:      &http.Client{Timeout: 10 * time.Second},
+		backoff:   500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout > 0 {
+		// Copy the HTTP client rather than change one passed in.
+		h := *c.http
+		h.Timeout = c.timeout
+		c.http = &h
+	}
+	return c
+}
+
+// Departures fetches the buses leaving a stop, by its 8 digit NapTAN code, as the site lists
+// them. A stop with no buses has no departures rather than an error.
+func (c *Client) Departures(ctx context.Context, stop string) ([]Departure, error) {
+	if !isNaptan(stop) {
+		return nil, fmt.Errorf("%w: %q isn't an 8 digit NapTAN code", bus.ErrInvalidStop, stop)
+	}
+	if c.cache != nil {
+		if departures, ok := c.cache.Get(stop); ok {
+			return departures, nil
+		}
+	}
+
+	site := acis.Client{BaseURL: c.baseURL, HTTPClient: c.http, UserAgent: c.userAgent}
+	wait := c.backoff
+	for try := 0; ; try++ {
+		departures, err := site.Departures(ctx, stop)
+		switch {
+		case err == nil:
+			if c.cache != nil {
+				c.cache.Put(stop, departures)
+			}
+			return departures, nil
+		case errors.Is(err, bus.ErrNoDepartures):
+			return []Departure{}, nil
+		case try >= c.retries || !bus.Retryable(err):
+			return nil, err
+		}
+
+		// Wait before trying again, unless the caller gives up first.
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+		wait = min(wait*2, maxBackoff)
+	}
+}
+
+// isNaptan checks a stop code is 8 digits, as ACIS takes.
+func isNaptan(stop string) bool {
+	if len(stop) != 8 {
+		return false
+	}
+	for _, r := range stop {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cache keeps boards between calls to Departures.
+type Cache interface {
+	// Get returns the board for a stop if it's still fresh.
+	Get(stop string) ([]Departure, bool)
+	// Put stores a freshly fetched board.
+	Put(stop string, departures []Departure)
+}
+
+// MemoryCache keeps boards in memory for a while.
+type MemoryCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	boards map[string]board
+}
+
+// board is a board kept in a MemoryCache.
+type board struct {
+	departures []Departure
+	fetched    time.Time
+}
+
+// NewMemoryCache makes a cache keeping boards for ttl.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, boards: map[string]board{}}
+}
+
+// Get returns the board for a stop if it was fetched within the ttl.
+func (m *MemoryCache) Get(stop string) ([]Departure, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.boards[stop]
+	if !ok || time.Since(b.fetched) > m.ttl {
+		return nil, false
+	}
+	return b.departures, true
+}
+
+// Put stores a board.
+func (m *MemoryCache) Put(stop string, departures []Departure) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.boards[stop] = board{departures, time.Now()}
+}
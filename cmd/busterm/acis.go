@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/return/busterm/pkg/acis"
+)
+
+// ACIS scrapes the text departure pages of ACIS Connect sites, such as yorkshire.acisconnect.com.
+// It's the default provider.
+type ACIS struct {
+	// BaseURL is the departure page. (http://yorkshire.acisconnect.com/Text/WebDisplay.aspx)
+	BaseURL string
+	// HTTPClient fetches the page, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (a ACIS) String() string {
+	return "acis"
+}
+
+// Capabilities of ACIS: live estimates, timetabled times when there are none, and low floor buses.
+func (a ACIS) Capabilities() Capabilities {
+	return Capabilities{Realtime: true, Scheduled: true, DoubleDecker: true}
+}
+
+// FetchDepartures fetches an array of buses by scraping from Yorkshire Buses.
+func (a ACIS) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	// Reuse connections to the upstream site, and count from the simulated clock when replaying.
+	return acis.Client{BaseURL: a.BaseURL, HTTPClient: orUpstream(a.HTTPClient), Now: clock}.Departures(ctx, ref)
+}
+
+// Preconnect opens a connection to the upstream site ahead of the first fetch,
+// so the DNS lookup and handshakes are out of the way on high latency links.
+func (a ACIS) Preconnect() error {
+	// Don't wait forever, even with no timeout on fetches.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "HEAD", a.BaseURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := orUpstream(a.HTTPClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("%w: the upstream site says %s", ErrUpstreamDown, res.Status)
+	}
+	return nil
+}
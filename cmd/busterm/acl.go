@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// ACL decides which IP addresses may use the API.
+type ACL struct {
+	mu    sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewACL parses allow and deny lists of CIDR ranges or single addresses.
+func NewACL(allow, deny []string) (*ACL, error) {
+	acl := &ACL{}
+	var err error
+	if acl.allow, err = prefixes(allow); err != nil {
+		return nil, err
+	}
+	if acl.deny, err = prefixes(deny); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// Replace swaps in new allow and deny lists, when the config file is reloaded.
+func (acl *ACL) Replace(with *ACL) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+	acl.allow, acl.deny = with.allow, with.deny
+}
+
+// prefixes parses CIDR ranges, treating a plain address as a range of one.
+func prefixes(ranges []string) ([]netip.Prefix, error) {
+	out := []netip.Prefix{}
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if !strings.Contains(r, "/") {
+			addr, err := netip.ParseAddr(r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, prefix.Masked())
+	}
+	return out, nil
+}
+
+// Allowed checks an address against the lists. Deny wins, and an empty allow list allows everyone.
+func (acl *ACL) Allowed(addr netip.Addr) bool {
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+	addr = addr.Unmap()
+	for _, prefix := range acl.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(acl.allow) == 0 {
+		return true
+	}
+	for _, prefix := range acl.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check wraps a handler, rejecting requests from addresses that aren't allowed.
+func (acl *ACL) Check(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil || !acl.Allowed(addr) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(403)
+			w.Write([]byte(forbidden))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
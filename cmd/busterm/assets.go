@@ -0,0 +1,40 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// embedded are the data files built into busterm, so a copied binary works on its own: the
+// NaPTAN stops list and the ACIS regions.
+//
+//go:embed assets
+var embedded embed.FS
+
+// assetDir holds files that replace the embedded ones by name, such as the whole country's
+// NaPTAN list. (assets in the config file, default ~/.config/busterm/assets)
+var assetDir = defaultAssetDir()
+
+// defaultAssetDir is the assets directory next to the config file.
+func defaultAssetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "busterm", "assets")
+}
+
+// readAsset reads a data file from the assets directory, or else the one built in.
+func readAsset(name string) ([]byte, error) {
+	if assetDir != "" {
+		data, err := os.ReadFile(filepath.Join(assetDir, name))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
+	}
+	return embedded.ReadFile(path.Join("assets", name))
+}
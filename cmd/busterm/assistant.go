@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// alexaRequest is the part of an Alexa Skills request busterm uses: the stop slot of an intent.
+// Launching the skill without one asks about the stop in the URL.
+type alexaRequest struct {
+	Request struct {
+		Type   string `json:"type"`
+		Intent struct {
+			Slots map[string]struct {
+				Value string `json:"value"`
+			} `json:"slots"`
+		} `json:"intent"`
+	} `json:"request"`
+}
+
+// alexaResponse is a plain text answer that ends the session.
+type alexaResponse struct {
+	Version  string `json:"version"`
+	Response struct {
+		OutputSpeech struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"outputSpeech"`
+		ShouldEndSession bool `json:"shouldEndSession"`
+	} `json:"response"`
+}
+
+// googleRequest is the part of a Google Actions webhook request busterm uses: the stop
+// parameter of the intent and the session to answer in.
+type googleRequest struct {
+	Intent struct {
+		Params map[string]struct {
+			Resolved any `json:"resolved"`
+		} `json:"params"`
+	} `json:"intent"`
+	Session struct {
+		ID string `json:"id"`
+	} `json:"session"`
+}
+
+// googleResponse is a simple spoken prompt.
+type googleResponse struct {
+	Session struct {
+		ID     string         `json:"id"`
+		Params map[string]any `json:"params"`
+	} `json:"session"`
+	Prompt struct {
+		Override    bool `json:"override"`
+		FirstSimple struct {
+			Speech string `json:"speech"`
+			Text   string `json:"text"`
+		} `json:"firstSimple"`
+	} `json:"prompt"`
+}
+
+// spokenBoard answers "when's the next bus?" for a stop, a code or alias, with the summary.
+func (s *Server) spokenBoard(ctx context.Context, code string, f Filter) string {
+	code = resolveAlias(strings.Join(strings.Fields(code), ""))
+	if code == "" {
+		return "Which stop? Add ?naptan= and the stop's code to the webhook's address, or name a stop."
+	}
+	if err := checkCode(code); err != nil {
+		return "I don't know that stop."
+	}
+	buses, err := s.lookup(ctx, code)
+	if err != nil {
+		return "Bus times aren't available right now."
+	}
+	return summary(f.Apply(buses))
+}
+
+// serveAlexa answers an Alexa Skills request with the spoken summary of the stop in the
+// request's stop slot, or else the one in the URL. (?naptan=45010687)
+func (s *Server) serveAlexa(w http.ResponseWriter, r *http.Request) {
+	var request alexaRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&request); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write([]byte(unable))
+		return
+	}
+	code := r.URL.Query().Get("naptan")
+	if slot := request.Request.Intent.Slots["stop"].Value; slot != "" {
+		code = slot
+	}
+
+	var response alexaResponse
+	response.Version = "1.0"
+	response.Response.OutputSpeech.Type = "PlainText"
+	response.Response.OutputSpeech.Text = s.spokenBoard(r.Context(), code, requestFilter(r))
+	response.Response.ShouldEndSession = true
+	writeJSON(w, response)
+}
+
+// serveGoogle answers a Google Actions webhook request with the spoken summary of the stop in
+// the intent's stop parameter, or else the one in the URL. (?naptan=45010687)
+func (s *Server) serveGoogle(w http.ResponseWriter, r *http.Request) {
+	var request googleRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&request); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write([]byte(unable))
+		return
+	}
+	code := r.URL.Query().Get("naptan")
+	if param, ok := request.Intent.Params["stop"]; ok {
+		if s, ok := param.Resolved.(string); ok && s != "" {
+			code = s
+		}
+	}
+
+	var response googleResponse
+	response.Session.ID = request.Session.ID
+	response.Session.Params = map[string]any{}
+	speech := s.spokenBoard(r.Context(), code, requestFilter(r))
+	response.Prompt.FirstSimple.Speech = speech
+	response.Prompt.FirstSimple.Text = speech
+	writeJSON(w, response)
+}
+
+// writeJSON sends a value as JSON.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	data, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(unable))
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(data)
+}
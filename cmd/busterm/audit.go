@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Auditor appends a JSON line for every API request to the audit log.
+type Auditor struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// auditEntry is a single line of the audit log.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	IP       string    `json:"ip"`
+	Key      string    `json:"key,omitempty"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Stop     string    `json:"stop,omitempty"`
+	Status   int       `json:"status"`
+	Outcome  string    `json:"outcome"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// OpenAuditor opens the audit log for appending, creating it if needed.
+func OpenAuditor(path string) (*Auditor, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Auditor{file: f}, nil
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before sending it.
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Audit wraps a handler, logging every request once it has been answered. Keys are named as
+// they are in keys, if it isn't nil.
+func (a *Auditor) Audit(next http.Handler, keys *Quotas) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: 200}
+		next.ServeHTTP(rec, r)
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		a.write(auditEntry{
+			Time:     start,
+			IP:       ip,
+			Key:      keyLabel(keys, apiKey(r)),
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Stop:     stopOf(r),
+			Status:   rec.status,
+			Outcome:  outcome(rec.status),
+			Duration: float64(time.Since(start).Microseconds()) / 1000,
+		})
+	})
+}
+
+// write appends an entry to the log. Lines are never interleaved.
+func (a *Auditor) write(entry auditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.file.Write(append(data, '\n'))
+}
+
+// apiKey returns the API key sent with a request, from the X-API-Key header or the key parameter.
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// keyLabel names an API key for the audit log without giving the secret away: by the name it
+// has in the config file, or a short hash for keys without one. ("kiosk", "sha256:9f86d081")
+func keyLabel(keys *Quotas, key string) string {
+	if key == "" {
+		return ""
+	}
+	if keys != nil {
+		if name, ok := keys.Name(key); ok && name != "" {
+			return name
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// stopOf returns the stop a request asked about, from the naptan parameter or a /v1/stops/ path.
+func stopOf(r *http.Request) string {
+	if code := r.URL.Query().Get("naptan"); code != "" {
+		return code
+	}
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/v1/stops/"); ok {
+		code, _, _ := strings.Cut(rest, "/")
+		return code
+	}
+	return ""
+}
+
+// outcome names what happened to a request from its status code.
+func outcome(status int) string {
+	switch {
+	case status < 400:
+		return "ok"
+	case status == 400:
+		return "invalid"
+	case status == 401:
+		return "unauthorized"
+	case status == 403:
+		return "forbidden"
+	case status == 429:
+		return "rate_limited"
+	case status == 500:
+		return "parse_error"
+	case status == 502:
+		return "upstream_down"
+	case status == 503:
+		return "busy"
+	}
+	return "error"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+var (
+	// keyringService is the name credentials are stored under in the OS keyring.
+	keyringService = "busterm"
+
+	// credentialProviders are the providers that need credentials.
+	credentialProviders = []string{"transportapi", "bods", "tfl", "siri", "remote"}
+
+	// credentials from the config file, used when the keyring has none.
+	credentials = map[string]string{}
+)
+
+// checkProvider makes sure credentials are for a provider we know about.
+func checkProvider(provider string) error {
+	for _, p := range credentialProviders {
+		if p == provider {
+			return nil
+		}
+	}
+	return errors.New("unknown provider: " + provider + " (expected " + strings.Join(credentialProviders, ", ") + ")")
+}
+
+// credential returns the credential of a provider, from the keyring or else the config file.
+func credential(provider string) (string, error) {
+	secret, err := keyring.Get(keyringService, provider)
+	if err == nil {
+		return secret, nil
+	}
+	if secret, ok := credentials[provider]; ok {
+		return secret, nil
+	}
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", errors.New("no credentials for " + provider + ", add them with: busterm auth set " + provider)
+	}
+	return "", err
+}
+
+// setCredential asks for a provider's credential and stores it in the keyring.
+func setCredential(provider string) error {
+	if err := checkProvider(provider); err != nil {
+		return err
+	}
+
+	fmt.Printf("Credential for %s (TransportAPI takes app_id:app_key): ", provider)
+	var secret string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		// Don't echo secrets to the screen.
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		secret = string(data)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		secret = line
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return errors.New("no credential given.")
+	}
+	return keyring.Set(keyringService, provider, secret)
+}
+
+// removeCredential deletes a provider's credential from the keyring.
+func removeCredential(provider string) error {
+	if err := checkProvider(provider); err != nil {
+		return err
+	}
+	err := keyring.Delete(keyringService, provider)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return errors.New("no credentials stored for " + provider)
+	}
+	return err
+}
+
+// listCredentials shows which providers have credentials and where they come from.
+func listCredentials() {
+	for _, provider := range credentialProviders {
+		where := "not set"
+		if _, err := keyring.Get(keyringService, provider); err == nil {
+			where = "keyring"
+		} else if _, ok := credentials[provider]; ok {
+			where = "config file"
+		}
+		fmt.Printf("%-14s %s\n", provider, where)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probe is the result of fetching one stop.
+type probe struct {
+	provider string
+	ref      string
+	latency  time.Duration
+	err      error
+}
+
+// readStops reads stop codes from a file, one per line. Blank lines and # comments are skipped.
+func readStops(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stops := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		code := resolveAlias(line)
+		if err := checkCode(code); err != nil {
+			return nil, errors.New(line + ": NapTAN code must be an 8 digit number.")
+		}
+		stops = append(stops, code)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stops) == 0 {
+		return nil, errors.New("no stops in " + path)
+	}
+	return stops, nil
+}
+
+// bench fetches every stop rounds times, with up to concurrency fetches at once.
+func bench(stops []string, concurrency int, rounds int) []probe {
+	jobs := make(chan string)
+	results := make(chan probe)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				start := time.Now()
+				_, err := getBuses(context.Background(), ref)
+				results <- probe{provider: fmt.Sprint(provider), ref: ref, latency: time.Since(start), err: err}
+			}
+		}()
+	}
+	go func() {
+		for r := 0; r < rounds; r++ {
+			for _, ref := range stops {
+				jobs <- ref
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	probes := []probe{}
+	for p := range results {
+		probes = append(probes, p)
+	}
+	return probes
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+// report prints the latency distribution and error rate of each provider.
+func report(probes []probe) {
+	byProvider := map[string][]probe{}
+	for _, p := range probes {
+		byProvider[p.provider] = append(byProvider[p.provider], p)
+	}
+	providers := []string{}
+	for name := range byProvider {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+
+	fmt.Printf("%-10s %8s %7s %7s %8s %8s %8s %8s %8s\n", "provider", "requests", "errors", "error%", "min", "p50", "p90", "p99", "max")
+	for _, name := range providers {
+		latencies := []time.Duration{}
+		errs := map[string]int{}
+		failed := 0
+		for _, p := range byProvider[name] {
+			if p.err != nil {
+				failed++
+				errs["["+errorKind(p.err)+"] "+p.err.Error()]++
+				continue
+			}
+			latencies = append(latencies, p.latency)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		total := len(byProvider[name])
+		ms := func(d time.Duration) string { return fmt.Sprintf("%dms", d.Milliseconds()) }
+		fmt.Printf("%-10s %8d %7d %6.1f%% %8s %8s %8s %8s %8s\n", name, total, failed, 100*float64(failed)/float64(total),
+			ms(percentile(latencies, 0)), ms(percentile(latencies, 50)), ms(percentile(latencies, 90)),
+			ms(percentile(latencies, 99)), ms(percentile(latencies, 100)))
+
+		// List what went wrong, most common first.
+		messages := []string{}
+		for msg := range errs {
+			messages = append(messages, msg)
+		}
+		sort.Slice(messages, func(i, j int) bool { return errs[messages[i]] > errs[messages[j]] })
+		for _, msg := range messages {
+			fmt.Printf("  %5d x %s\n", errs[msg], msg)
+		}
+	}
+}
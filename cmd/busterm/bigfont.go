@@ -0,0 +1,67 @@
+package main
+
+import "strings"
+
+// font is a 5 row block font used by the "large" layout for displays read from across a room.
+var font = map[rune][5]string{
+	'0': {" ## ", "#  #", "#  #", "#  #", " ## "},
+	'1': {" #  ", "##  ", " #  ", " #  ", "### "},
+	'2': {"### ", "   #", " ## ", "#   ", "####"},
+	'3': {"### ", "   #", " ## ", "   #", "### "},
+	'4': {"#  #", "#  #", "####", "   #", "   #"},
+	'5': {"####", "#   ", "### ", "   #", "### "},
+	'6': {" ## ", "#   ", "### ", "#  #", " ## "},
+	'7': {"####", "   #", "  # ", " #  ", " #  "},
+	'8': {" ## ", "#  #", " ## ", "#  #", " ## "},
+	'9': {" ## ", "#  #", " ###", "   #", " ## "},
+	'A': {" ## ", "#  #", "####", "#  #", "#  #"},
+	'B': {"### ", "#  #", "### ", "#  #", "### "},
+	'C': {" ###", "#   ", "#   ", "#   ", " ###"},
+	'D': {"### ", "#  #", "#  #", "#  #", "### "},
+	'E': {"####", "#   ", "### ", "#   ", "####"},
+	'F': {"####", "#   ", "### ", "#   ", "#   "},
+	'G': {" ###", "#   ", "# ##", "#  #", " ###"},
+	'H': {"#  #", "#  #", "####", "#  #", "#  #"},
+	'I': {"### ", " #  ", " #  ", " #  ", "### "},
+	'J': {"  ##", "   #", "   #", "#  #", " ## "},
+	'K': {"#  #", "# # ", "##  ", "# # ", "#  #"},
+	'L': {"#   ", "#   ", "#   ", "#   ", "####"},
+	'M': {"#  #", "####", "####", "#  #", "#  #"},
+	'N': {"#  #", "## #", "# ##", "#  #", "#  #"},
+	'O': {" ## ", "#  #", "#  #", "#  #", " ## "},
+	'P': {"### ", "#  #", "### ", "#   ", "#   "},
+	'Q': {" ## ", "#  #", "#  #", "# # ", " # #"},
+	'R': {"### ", "#  #", "### ", "# # ", "#  #"},
+	'S': {" ###", "#   ", " ## ", "   #", "### "},
+	'T': {"####", " #  ", " #  ", " #  ", " #  "},
+	'U': {"#  #", "#  #", "#  #", "#  #", " ## "},
+	'V': {"#  #", "#  #", "#  #", " ## ", " ## "},
+	'W': {"#  #", "#  #", "####", "####", "#  #"},
+	'X': {"#  #", "#  #", " ## ", "#  #", "#  #"},
+	'Y': {"#  #", "#  #", " ## ", " #  ", " #  "},
+	'Z': {"####", "   #", " ## ", "#   ", "####"},
+	':': {" ", "#", " ", "#", " "},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	'/': {"   #", "  # ", " #  ", "#   ", "    "},
+	' ': {"  ", "  ", "  ", "  ", "  "},
+}
+
+// big renders text in the block font. Characters without a glyph are left blank.
+func big(text string) string {
+	rows := [5][]string{}
+	for _, r := range strings.ToUpper(text) {
+		glyph, ok := font[r]
+		if !ok {
+			glyph = font[' ']
+		}
+		for i := range rows {
+			rows[i] = append(rows[i], glyph[i])
+		}
+	}
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i] = strings.TrimRight(strings.Join(row, " "), " ")
+	}
+	return strings.Join(lines, "\n")
+}
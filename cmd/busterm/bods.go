@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// bodsFeed is the Bus Open Data Service SIRI-VM datafeed, every bus in England. Fetches narrow
+// it to the area around the stop, unless the feed is already narrowed.
+var bodsFeed = "https://data.bus-data.dft.gov.uk/api/v1/datafeed/"
+
+// bodsArea is how far around a stop, in degrees of latitude, buses are fetched from BODS: about
+// 10km each way, far enough for buses a few stops off. Degrees of longitude are half again as
+// many, as they're shorter this far north.
+const bodsArea = 0.1
+
+// bodsFilters are the datafeed parameters that narrow it down.
+var bodsFilters = []string{"boundingBox", "operatorRef", "lineRef", "producerRef", "originRef", "destinationRef", "vehicleRef"}
+
+// BODS reads live departures from the DfT Bus Open Data Service. Unlike the scraper it
+// knows the operator and published line name of every bus. Stops are ATCO codes. (450010687)
+type BODS struct {
+	// Feed is the SIRI-VM datafeed URL. Narrow it with query parameters such as boundingBox or operatorRef.
+	Feed string
+	// APIKey is the BODS API key.
+	APIKey string
+	// HTTPClient fetches the feed, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (b BODS) String() string {
+	return "bods"
+}
+
+// Capabilities of BODS: expected times and the operator of every bus.
+func (b BODS) Capabilities() Capabilities {
+	return Capabilities{Realtime: true, Operator: true}
+}
+
+// CheckStop accepts ATCO codes.
+func (b BODS) CheckStop(ref string) error {
+	return checkATCO(ref)
+}
+
+// narrowed reports if a datafeed query is already narrowed to part of the country.
+func narrowed(query url.Values) bool {
+	for _, filter := range bodsFilters {
+		if query.Has(filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkATCO checks a stop is an ATCO code, which is up to 12 letters and digits.
+func checkATCO(ref string) error {
+	if ref == "" || len(ref) > 12 || strings.Trim(ref, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") != "" {
+		return fmt.Errorf("%w: %q is not an <error>ATCO code.<reset>\n", ErrInvalidStop, ref)
+	}
+	return nil
+}
+
+// bodsCall is a stop a vehicle is calling at.
+type bodsCall struct {
+	StopPointRef      string    `xml:"StopPointRef"`
+	AimedArrival      time.Time `xml:"AimedArrivalTime"`
+	ExpectedArrival   time.Time `xml:"ExpectedArrivalTime"`
+	AimedDeparture    time.Time `xml:"AimedDepartureTime"`
+	ExpectedDeparture time.Time `xml:"ExpectedDepartureTime"`
+}
+
+// bodsResponse is the part of a vehicle monitoring delivery busterm uses.
+type bodsResponse struct {
+	Activities []struct {
+		Journey struct {
+			LineRef           string     `xml:"LineRef"`
+			PublishedLineName string     `xml:"PublishedLineName"`
+			OperatorRef       string     `xml:"OperatorRef"`
+			DestinationName   string     `xml:"DestinationName"`
+			MonitoredCall     bodsCall   `xml:"MonitoredCall"`
+			OnwardCalls       []bodsCall `xml:"OnwardCalls>OnwardCall"`
+		} `xml:"MonitoredVehicleJourney"`
+	} `xml:"ServiceDelivery>VehicleMonitoringDelivery>VehicleActivity"`
+}
+
+// FetchDepartures returns the vehicles in the feed that are going to call at a stop.
+// Only operators that send their upcoming calls can be matched to stops.
+func (b BODS) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	feed, err := url.Parse(b.Feed)
+	if err != nil {
+		return []Bus{}, err
+	}
+	query := feed.Query()
+	if !narrowed(query) {
+		// The whole country is hundreds of megabytes, to find a few buses at one stop.
+		stop, ok := findStop(ref)
+		if !ok {
+			return []Bus{}, fmt.Errorf("%w: %s isn't in the NaPTAN stops list, so the BODS feed can't be narrowed to it. Give an --endpoint with a boundingBox or operatorRef.", ErrInvalidStop, ref)
+		}
+		query.Set("boundingBox", fmt.Sprintf("%.4f,%.4f,%.4f,%.4f",
+			stop.Longitude-1.5*bodsArea, stop.Latitude-bodsArea, stop.Longitude+1.5*bodsArea, stop.Latitude+bodsArea))
+	}
+	query.Set("api_key", b.APIKey)
+	feed.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feed.String(), nil)
+	if err != nil {
+		return []Bus{}, err
+	}
+	res, err := orUpstream(b.HTTPClient).Do(req)
+	if err != nil {
+		// Don't print the API key with the URL.
+		return []Bus{}, fmt.Errorf("%w: %s", ErrUpstreamDown, scrub(err.Error(), b.APIKey))
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 429:
+		return []Bus{}, fmt.Errorf("%w: BODS says %s", ErrRateLimited, res.Status)
+	case res.StatusCode == 401 || res.StatusCode == 403:
+		return []Bus{}, fmt.Errorf("BODS refused the API key: %s", res.Status)
+	case res.StatusCode != 200:
+		return []Bus{}, fmt.Errorf("%w: status != 200: status:%s", ErrUpstreamDown, res.Status)
+	}
+
+	var response bodsResponse
+	if err := xml.NewDecoder(res.Body).Decode(&response); err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+
+	now := clock()
+	departures := []departure{}
+	for _, activity := range response.Activities {
+		j := activity.Journey
+		for _, call := range append([]bodsCall{j.MonitoredCall}, j.OnwardCalls...) {
+			if call.StopPointRef != ref {
+				continue
+			}
+			at := firstTime(call.ExpectedDeparture, call.ExpectedArrival, call.AimedDeparture, call.AimedArrival)
+			if at.IsZero() || at.Before(now.Add(-time.Minute)) {
+				break
+			}
+			bus := Bus{Service: j.PublishedLineName, To: j.DestinationName, Operator: j.OperatorRef}
+			if bus.Service == "" {
+				bus.Service = j.LineRef
+			}
+			bus.DepartsAt(at, call.ExpectedDeparture.IsZero() && call.ExpectedArrival.IsZero(), now)
+			departures = append(departures, departure{bus, at})
+			break
+		}
+	}
+	return soonestFirst(departures), nil
+}
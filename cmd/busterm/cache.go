@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Cache keeps recently fetched boards so busy stops don't hit the upstream site on every request.
+type Cache interface {
+	// Get returns the cached board for a stop if it's still fresh.
+	Get(ref string) ([]Bus, bool)
+	// Put stores a freshly fetched board.
+	Put(ref string, buses []Bus)
+	// Last returns the last board stored for a stop however old it is, and when it was fetched.
+	Last(ref string) ([]Bus, time.Time, bool)
+}
+
+// CacheConfig selects and configures the cache backend.
+type CacheConfig struct {
+	// Backend is one of "memory", "bolt" or "redis". (default memory)
+	Backend string `toml:"backend"`
+	// TTL is how long boards are kept, such as "45s". (default 30s, a minute with --public)
+	TTL string `toml:"ttl"`
+	// Path is the database file of the bolt backend.
+	Path string `toml:"path"`
+	// URL is the address of the redis backend, such as "redis://localhost:6379/0".
+	URL string `toml:"url"`
+}
+
+// cached is a board kept in the cache.
+type cached struct {
+	Buses   []Bus     `json:"buses"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// openCache opens the configured cache backend.
+func openCache(config CacheConfig, ttl time.Duration) (Cache, error) {
+	if config.TTL != "" {
+		d, err := time.ParseDuration(config.TTL)
+		if err != nil {
+			return nil, err
+		}
+		ttl = d
+	}
+
+	switch config.Backend {
+	case "", "memory":
+		return NewMemoryCache(ttl), nil
+	case "bolt":
+		if config.Path == "" {
+			return nil, errors.New("the bolt cache needs a path.")
+		}
+		return OpenBoltCache(config.Path, ttl)
+	case "redis":
+		if config.URL == "" {
+			return nil, errors.New("the redis cache needs a url.")
+		}
+		return OpenRedisCache(config.URL, ttl)
+	}
+	return nil, errors.New("unknown cache backend: " + config.Backend)
+}
+
+// MemoryCache keeps boards in memory. They're lost when busterm stops.
+type MemoryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cached
+	// swept is when boards past keeping were last dropped.
+	swept time.Time
+}
+
+// NewMemoryCache creates a cache which keeps boards for ttl.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, entries: map[string]cached{}}
+}
+
+// Get returns the cached board for a stop if it's still fresh.
+func (c *MemoryCache) Get(ref string) ([]Bus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[ref]
+	if !ok || time.Since(entry.Fetched) > freshFor(c.ttl) {
+		return nil, false
+	}
+	return copyBuses(entry.Buses), true
+}
+
+// Put stores a freshly fetched board, and every ttl drops the boards past keeping, so a public
+// API asked for any number of stops doesn't grow forever.
+func (c *MemoryCache) Put(ref string, buses []Bus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.entries[ref] = cached{Buses: copyBuses(buses), Fetched: now}
+	if now.Sub(c.swept) < c.ttl {
+		return
+	}
+	c.swept = now
+	for ref, entry := range c.entries {
+		if now.Sub(entry.Fetched) > keptFor(c.ttl) {
+			delete(c.entries, ref)
+		}
+	}
+}
+
+// Last returns the last board stored for a stop however old it is, and when it was fetched.
+func (c *MemoryCache) Last(ref string) ([]Bus, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[ref]
+	return copyBuses(entry.Buses), entry.Fetched, ok
+}
+
+// boards is the bolt bucket boards are kept in.
+var boards = []byte("boards")
+
+// BoltCache keeps boards in a bolt database file, so they survive restarts.
+type BoltCache struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+// OpenBoltCache opens (or creates) a bolt database for the cache.
+func OpenBoltCache(path string, ttl time.Duration) (*BoltCache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boards)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltCache{db: db, ttl: ttl}, nil
+}
+
+// Get returns the cached board for a stop if it's still fresh.
+func (c *BoltCache) Get(ref string) ([]Bus, bool) {
+	buses, fetched, found := c.Last(ref)
+	if !found || time.Since(fetched) > freshFor(c.ttl) {
+		return nil, false
+	}
+	return buses, true
+}
+
+// Last returns the last board stored for a stop however old it is, and when it was fetched.
+func (c *BoltCache) Last(ref string) ([]Bus, time.Time, bool) {
+	var entry cached
+	found := false
+	c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boards).Get([]byte(ref))
+		found = data != nil && json.Unmarshal(data, &entry) == nil
+		return nil
+	})
+	return entry.Buses, entry.Fetched, found
+}
+
+// Put stores a freshly fetched board.
+func (c *BoltCache) Put(ref string, buses []Bus) {
+	data, err := json.Marshal(cached{Buses: buses, Fetched: time.Now()})
+	if err != nil {
+		return
+	}
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boards).Put([]byte(ref), data)
+	})
+	if err != nil {
+		log.Println("cache:", err)
+	}
+}
+
+// RedisCache keeps boards in redis, so several busterm instances can share them.
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// OpenRedisCache connects to redis for the cache.
+func OpenRedisCache(url string, ttl time.Duration) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+	return &RedisCache{client: client, ttl: ttl}, nil
+}
+
+// Get returns the cached board for a stop if it's still fresh.
+func (c *RedisCache) Get(ref string) ([]Bus, bool) {
+	buses, fetched, found := c.Last(ref)
+	if !found || time.Since(fetched) > freshFor(c.ttl) {
+		return nil, false
+	}
+	return buses, true
+}
+
+// Last returns the last board stored for a stop while redis keeps it, and when it was fetched.
+func (c *RedisCache) Last(ref string) ([]Bus, time.Time, bool) {
+	data, err := c.client.Get(context.Background(), "busterm:board:"+ref).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Println("cache:", err)
+		}
+		return nil, time.Time{}, false
+	}
+	var entry cached
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, time.Time{}, false
+	}
+	return entry.Buses, entry.Fetched, true
+}
+
+// Put stores a freshly fetched board. Redis keeps it for a while after it's no longer fresh, for
+// Last.
+func (c *RedisCache) Put(ref string, buses []Bus) {
+	data, err := json.Marshal(cached{Buses: buses, Fetched: time.Now()})
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(context.Background(), "busterm:board:"+ref, data, keptFor(c.ttl)).Err(); err != nil {
+		log.Println("cache:", err)
+	}
+}
+
+// freshFor is how long a board is kept: the cache's ttl, for longer while the upstream site
+// struggles. Boards looks polled stops up by their interval instead.
+func freshFor(ttl time.Duration) time.Duration {
+	return load.Stretch(ttl)
+}
+
+// staleKept is how long a board is kept once it's no longer fresh, for Last to serve while the
+// upstream site struggles.
+const staleKept = 10 * time.Minute
+
+// keptFor is how long a board is kept at all: while it's fresh, and then staleKept longer.
+func keptFor(ttl time.Duration) time.Duration {
+	return freshFor(ttl) + staleKept
+}
+
+// copyBuses copies a board so callers can't change what's cached.
+func copyBuses(buses []Bus) []Bus {
+	out := make([]Bus, len(buses))
+	copy(out, buses)
+	return out
+}
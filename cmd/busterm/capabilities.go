@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/return/busterm/pkg/render"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// Capabilities say what a provider can fill in, so UIs can hide columns a source never fills.
+type Capabilities struct {
+	// Realtime is set when departures come from live estimates.
+	Realtime bool `json:"realtime"`
+	// Scheduled is set when timetabled times are given when there's no estimate.
+	Scheduled bool `json:"scheduled"`
+	// Occupancy is set when buses say how full they are.
+	Occupancy bool `json:"occupancy"`
+	// VehiclePositions is set when buses say where they are.
+	VehiclePositions bool `json:"vehicle_positions"`
+	// Operator is set when buses name their operator.
+	Operator bool `json:"operator"`
+	// DoubleDecker is set when double deckers are told apart from low floor buses.
+	DoubleDecker bool `json:"double_decker"`
+}
+
+// capable is a provider that declares its capabilities.
+type capable interface {
+	Capabilities() Capabilities
+}
+
+// capabilitiesOf returns what a provider can fill in. Providers that don't say are taken to give
+// live estimates only.
+func capabilitiesOf(p Provider) Capabilities {
+	if p, ok := p.(capable); ok {
+		return p.Capabilities()
+	}
+	return Capabilities{Realtime: true}
+}
+
+// Or combines capabilities, for providers that stand for several others.
+func (c Capabilities) Or(other Capabilities) Capabilities {
+	return Capabilities{
+		Realtime:         c.Realtime || other.Realtime,
+		Scheduled:        c.Scheduled || other.Scheduled,
+		Occupancy:        c.Occupancy || other.Occupancy,
+		VehiclePositions: c.VehiclePositions || other.VehiclePositions,
+		Operator:         c.Operator || other.Operator,
+		DoubleDecker:     c.DoubleDecker || other.DoubleDecker,
+	}
+}
+
+// sourceProvider returns an unconfigured provider for a source, to ask about its capabilities.
+func sourceProvider(name string) Provider {
+	switch name {
+	case "acis":
+		return ACIS{}
+	case "siri":
+		return SIRI{}
+	case "gtfs-rt":
+		return GTFSRT{}
+	case "bods":
+		return BODS{}
+	case "tfl":
+		return &TfL{}
+	case "remote":
+		return Remote{}
+	}
+	return Saved{}
+}
+
+// ProviderInfo describes a source in /v1/providers.
+type ProviderInfo struct {
+	Name         string       `json:"name"`
+	Active       bool         `json:"active"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// providerInfo lists every source and the saved boards, marking the ones in use.
+func providerInfo() []ProviderInfo {
+	active := map[string]bool{}
+	for _, name := range sourceNames(provider) {
+		active[name] = true
+	}
+	infos := []ProviderInfo{}
+	for _, name := range append(append([]string{}, sources...), "cached") {
+		infos = append(infos, ProviderInfo{name, active[name], capabilitiesOf(sourceProvider(name))})
+	}
+	return infos
+}
+
+// sourceNames names the sources a provider fetches from. ("acis", "tfl" and "cached" for ACIS
+// with London stops and a failover to saved boards)
+func sourceNames(p Provider) []string {
+	switch p := p.(type) {
+	case Failover:
+		names := []string{}
+		for _, each := range p {
+			names = append(names, sourceNames(each)...)
+		}
+		return names
+	case London:
+		return append(sourceNames(p.Elsewhere), "tfl")
+	}
+	return []string{fmt.Sprint(p)}
+}
+
+// serveProviders sends the sources busterm knows, what each can fill in and which are in use.
+func serveProviders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	data, err := json.Marshal(providerInfo())
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Fprint(w, unable)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// listProviders prints the sources busterm knows and what each can fill in.
+func listProviders(c clif.Output) {
+	yes := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "-"
+	}
+	rows := [][]string{}
+	for _, p := range providerInfo() {
+		name := p.Name
+		if p.Active {
+			name = "<headline>" + name + "<reset>"
+		}
+		caps := p.Capabilities
+		rows = append(rows, []string{name, yes(caps.Realtime), yes(caps.Scheduled), yes(caps.Occupancy), yes(caps.VehiclePositions), yes(caps.Operator), yes(caps.DoubleDecker)})
+	}
+	c.Printf("%s\n", render.Columns([]string{"Source", "Realtime", "Scheduled", "Occupancy", "Positions", "Operator", "Double Decker"}, rows, 0))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+var (
+	// confidence markers shown next to the time.
+	steady   = "steady"
+	volatile = "volatile"
+
+	// number of refreshes remembered for each journey.
+	confidenceWindow = 5
+	// how far an estimate may drift across refreshes before it's volatile.
+	volatileSpread = 2 * time.Minute
+	// estimates further apart than this are treated as different journeys.
+	journeyGap = 15 * time.Minute
+)
+
+// journey holds the estimated departures seen for a single bus across refreshes.
+type journey struct {
+	estimates []time.Time
+	// bus is how the journey looked at the last refresh.
+	bus Bus
+}
+
+// last returns the most recent estimate of the journey.
+func (j journey) last() time.Time {
+	return j.estimates[len(j.estimates)-1]
+}
+
+// Tracker remembers how each journey's countdown changes between refreshes.
+type Tracker struct {
+	journeys map[string][]journey
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{journeys: map[string][]journey{}}
+}
+
+// Observe records the current estimates and marks each bus as steady or volatile.
+// It returns what changed since the last refresh.
+func (t *Tracker) Observe(buses []Bus, now time.Time) []Event {
+	next := map[string][]journey{}
+	used := map[string]map[int]bool{}
+	events := []Event{}
+
+	for i := range buses {
+		bus := &buses[i]
+		eta := bus.Expected
+		if eta.IsZero() {
+			continue
+		}
+
+		// Match the bus against the closest journey seen last time on the same route.
+		route := bus.Service + "|" + bus.To
+		if used[route] == nil {
+			used[route] = map[int]bool{}
+		}
+		match := -1
+		for j, prev := range t.journeys[route] {
+			if used[route][j] || absDuration(prev.last().Sub(eta)) > journeyGap {
+				continue
+			}
+			if match == -1 || absDuration(prev.last().Sub(eta)) < absDuration(t.journeys[route][match].last().Sub(eta)) {
+				match = j
+			}
+		}
+
+		// Carry the history over, keeping only the last few estimates.
+		estimates := []time.Time{}
+		if match != -1 {
+			used[route][match] = true
+			prev := t.journeys[route][match]
+			estimates = append(estimates, prev.estimates...)
+			if prev.bus.Time != bus.Time || prev.bus.DoubleDecker != bus.DoubleDecker {
+				events = append(events, Event{Type: changed, Time: now, Bus: *bus, Previous: &prev.bus})
+			}
+		} else {
+			events = append(events, Event{Type: arrived, Time: now, Bus: *bus})
+		}
+		estimates = append(estimates, eta)
+		if len(estimates) > confidenceWindow {
+			estimates = estimates[len(estimates)-confidenceWindow:]
+		}
+		next[route] = append(next[route], journey{estimates: estimates, bus: *bus})
+
+		// We need at least two refreshes before we can say anything.
+		if len(estimates) < 2 {
+			continue
+		}
+		bus.Confidence = steady
+		if spread(estimates) > volatileSpread {
+			bus.Confidence = volatile
+		}
+	}
+
+	// Journeys we didn't see again have left the stop.
+	routes := []string{}
+	for route := range t.journeys {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		for j, prev := range t.journeys[route] {
+			if !used[route][j] {
+				events = append(events, Event{Type: departed, Time: now, Bus: prev.bus})
+			}
+		}
+	}
+
+	t.journeys = next
+	return events
+}
+
+// spread returns the difference between the earliest and latest estimate.
+func spread(estimates []time.Time) time.Duration {
+	earliest, latest := estimates[0], estimates[0]
+	for _, e := range estimates[1:] {
+		if e.Before(earliest) {
+			earliest = e
+		}
+		if e.After(latest) {
+			latest = e
+		}
+	}
+	return latest.Sub(earliest)
+}
+
+// absDuration returns the absolute value of a duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
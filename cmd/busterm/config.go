@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings read from the busterm config file.
+type Config struct {
+	// Profiles are named render profiles, merged over the built-in ones.
+	Profiles map[string]Profile `toml:"profiles"`
+	// Destinations are extra substitutions for destination names. ("LS CITY CTR" = "Leeds City Centre")
+	Destinations map[string]string `toml:"destinations"`
+	// Groups are named sets of destinations, used with --towards. ("City Centre" = ["Leeds", "City Square"])
+	Groups map[string][]string `toml:"groups"`
+	// Services renames or merges services for display. ("PR1/PR2" = "Park & Ride")
+	Services map[string]string `toml:"services"`
+	// Glyphs are the symbols used for buses and stops.
+	Glyphs Glyphs `toml:"glyphs"`
+	// Credentials of providers, used when the OS keyring has none. Prefer `busterm auth set`.
+	Credentials map[string]string `toml:"credentials"`
+	// API holds the settings of the API server.
+	API APIConfig `toml:"api"`
+	// Cache selects where the API keeps boards.
+	Cache CacheConfig `toml:"cache"`
+	// Schedule lists the jobs run by `busterm schedule`.
+	Schedule []Job `toml:"schedule"`
+	// Stops are the stops whose last board is kept across restarts. (the watched stop always is)
+	Stops []string `toml:"stops"`
+	// WarmFile is where those boards are kept. ("off" to disable, default ~/.cache/busterm/warm.json)
+	WarmFile string `toml:"warm_file"`
+	// Region is the ACIS Connect region to fetch from, such as "cambridgeshire". (default yorkshire)
+	Region string `toml:"region"`
+	// BaseURL is the departure page of an ACIS Connect site, for regions busterm doesn't know.
+	BaseURL string `toml:"base_url"`
+	// Assets is a directory of data files that replace the built-in ones, such as naptan.csv.gz
+	// and regions.toml. (default ~/.config/busterm/assets)
+	Assets string `toml:"assets"`
+	// Failover lists the sources tried in order when the main one fails. (source = "cached" for saved boards)
+	Failover []Source `toml:"failover"`
+	// Weather shows a warning on the board when the weather near the stop tends to delay buses.
+	Weather bool `toml:"weather"`
+	// Notes are notes about stops, shown under the board's heading. ("45010687" = ["Shelter broken"])
+	Notes map[string][]string `toml:"notes"`
+	// Aliases are names for stops, usable wherever a stop code is. ("home" = "45010687")
+	Aliases map[string]string `toml:"aliases"`
+	// Defaults are values for flags that aren't given, by the flag's name, and the stop and refresh
+	// interval used when there are none. (profile = "tv", stop = "45010687", interval = "1m")
+	Defaults map[string]any `toml:"defaults"`
+	// Matrix is the account `busterm bot --matrix` runs as.
+	Matrix MatrixConfig `toml:"matrix"`
+	// IRC is the server and nick `busterm bot --irc` uses.
+	IRC IRCConfig `toml:"irc"`
+}
+
+// APIConfig holds the settings of the API server.
+type APIConfig struct {
+	// Allow lists the CIDR ranges that may use the API. (empty = everyone)
+	Allow []string `toml:"allow"`
+	// Deny lists the CIDR ranges that may never use the API, even when allowed.
+	Deny []string `toml:"deny"`
+	// AuditLog is a file every request is appended to as a JSON line. (empty = off)
+	AuditLog string `toml:"audit_log"`
+	// Keys are the API keys clients must use, with their quotas. (empty = no keys needed)
+	Keys []KeyConfig `toml:"keys"`
+	// Poll lists stops to keep fresh in the background, with how often to fetch each. They're
+	// cached for that long too. ("45010687" = "15s")
+	Poll map[string]string `toml:"poll"`
+}
+
+// Profile describes how the board is rendered on a particular display.
+type Profile struct {
+	// Layout is one of "table", "oneline" or "large".
+	Layout string `toml:"layout"`
+	// Monochrome turns off colours.
+	Monochrome bool `toml:"monochrome"`
+	// Compact hides the legend and the emoji column.
+	Compact bool `toml:"compact"`
+	// Width is the maximum width of the table. (0 = unlimited)
+	Width int `toml:"width"`
+	// Rows is the maximum number of departures shown. (0 = all)
+	Rows int `toml:"rows"`
+	// Window is how many minutes ahead the emoji road reaches. (0 = 60)
+	Window int `toml:"window"`
+	// Cycle is how many seconds each page is shown in watch mode when the departures
+	// don't fit on one. (0 = only show the first page)
+	Cycle int `toml:"cycle"`
+}
+
+var (
+	// profiles bundled with busterm, selectable with --profile.
+	profiles = map[string]Profile{
+		"default":   {Layout: "table"},
+		"tv":        {Layout: "large", Monochrome: true, Cycle: 8},
+		"phone-ssh": {Layout: "table", Compact: true, Width: 50, Rows: 5},
+		"statusbar": {Layout: "oneline", Monochrome: true, Rows: 3},
+	}
+
+	// display is the profile used to render the board.
+	display = profiles["default"]
+)
+
+// configPath returns the default location of the config file: config.toml, or else config.yaml
+// or config.yml when there's one of those instead.
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		path := filepath.Join(dir, "busterm", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, "busterm", "config.toml")
+}
+
+// loadConfig reads the config file at path. A missing default config is not an error.
+func loadConfig(path string) (Config, error) {
+	config := Config{}
+	explicit := path != ""
+	if !explicit {
+		path = configPath()
+	}
+	if path == "" {
+		return config, nil
+	}
+
+	err := decodeConfig(path, &config)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	return config, config.resolveSecrets()
+}
+
+// decodeConfig reads a TOML or, going by its extension, YAML config file.
+func decodeConfig(path string, config *Config) error {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		doc := map[string]any{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		// Read the YAML as the TOML it mirrors, so both use the same names.
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		if _, err := toml.Decode(buf.String(), config); err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		return nil
+	}
+	_, err := toml.DecodeFile(path, config)
+	return err
+}
+
+// envRef matches ${NAME} references to environment variables.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveSecret reads a secret from a file ("file:/run/secrets/bods") or fills in
+// ${ENV} references, so secrets don't have to live in the config file.
+func resolveSecret(value string) (string, error) {
+	if path, ok := strings.CutPrefix(value, "file:"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	var err error
+	resolved := envRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRef.FindStringSubmatch(ref)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = errors.New("environment variable " + name + " is not set")
+		}
+		return env
+	})
+	return resolved, err
+}
+
+// resolveSecrets resolves the references in every secret in the config.
+func (config *Config) resolveSecrets() error {
+	for provider, value := range config.Credentials {
+		secret, err := resolveSecret(value)
+		if err != nil {
+			return errors.New("credentials." + provider + ": " + err.Error())
+		}
+		config.Credentials[provider] = secret
+	}
+	for i, source := range config.Failover {
+		secret, err := resolveSecret(source.APIKey)
+		if err != nil {
+			return errors.New("failover." + source.Source + ".api_key: " + err.Error())
+		}
+		config.Failover[i].APIKey = secret
+	}
+	token, err := resolveSecret(config.Matrix.AccessToken)
+	if err != nil {
+		return errors.New("matrix.access_token: " + err.Error())
+	}
+	config.Matrix.AccessToken = token
+	password, err := resolveSecret(config.IRC.Password)
+	if err != nil {
+		return errors.New("irc.password: " + err.Error())
+	}
+	config.IRC.Password = password
+	for i, key := range config.API.Keys {
+		secret, err := resolveSecret(key.Key)
+		if err != nil {
+			return errors.New("api.keys." + key.Name + ": " + err.Error())
+		}
+		config.API.Keys[i].Key = secret
+	}
+	return nil
+}
+
+// profile looks up a render profile by name, preferring the config file over the built-ins.
+func (config Config) profile(name string) (Profile, error) {
+	if p, ok := config.Profiles[name]; ok {
+		return p, nil
+	}
+	if p, ok := profiles[name]; ok {
+		return p, nil
+	}
+	return Profile{}, errors.New("unknown profile: " + name)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix starts the environment variables that stand in for flags. (BUSTERM_BASE_URL)
+const envPrefix = "BUSTERM_"
+
+// applyDefaults fills in the flags that weren't given on the command line from BUSTERM_ environment
+// variables, then from the config file's [defaults], such as profile = "tv". Flags given always win.
+func (config *Config) applyDefaults(arguments map[string]interface{}, args []string, env []string) error {
+	values := map[string]any{}
+	for name, value := range config.Defaults {
+		values[name] = value
+	}
+	for name, value := range envDefaults(arguments, env) {
+		values[name] = value
+	}
+	config.Defaults = values
+
+	for name, value := range values {
+		switch name {
+		case "stop":
+			// Shown instead of the stop picker.
+			if _, ok := value.(string); !ok {
+				return errors.New("the default stop must be a stop code or alias, in quotes.")
+			}
+			continue
+		case "interval":
+			if arguments["<interval>"] == nil {
+				arguments["<interval>"] = fmt.Sprint(value)
+			}
+			continue
+		}
+
+		flag := "--" + name
+		current, known := arguments[flag]
+		if !known || name == "config" || name == "help" || name == "version" {
+			return errors.New("unknown flag in the defaults: " + name)
+		}
+		if given(flag, args) {
+			continue
+		}
+		// Switches such as offline take true or false, the rest take their value.
+		if _, ok := current.(bool); ok {
+			on, ok := value.(bool)
+			if s, isString := value.(string); isString {
+				var err error
+				on, err = strconv.ParseBool(s)
+				ok = err == nil
+			}
+			if !ok {
+				return errors.New("the default " + name + " must be true or false.")
+			}
+			arguments[flag] = on
+			continue
+		}
+		arguments[flag] = fmt.Sprint(value)
+	}
+	return nil
+}
+
+// envDefaults reads the BUSTERM_ environment variables naming flags, such as BUSTERM_PORT for
+// --port, or the stop (BUSTERM_NAPTAN) and interval (BUSTERM_INTERVAL). Others are left alone,
+// as the environment is shared.
+func envDefaults(arguments map[string]interface{}, env []string) map[string]any {
+	values := map[string]any{}
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, envPrefix)
+		if !ok || value == "" {
+			continue
+		}
+		name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+		switch name {
+		case "naptan", "stop":
+			values["stop"] = value
+		case "interval":
+			values["interval"] = value
+		case "config", "help", "version":
+		default:
+			if _, known := arguments["--"+name]; known {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
+
+// given reports if a flag is on the command line, as --flag value or --flag=value.
+func given(flag string, args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultStop returns the stop from the config file's [defaults], if there is one.
+func (config Config) defaultStop() (string, bool) {
+	stop, ok := config.Defaults["stop"].(string)
+	return stop, ok && stop != ""
+}
+
+// minInterval is the shortest refresh interval, to go easy on the upstream site.
+var minInterval = 10 * time.Second
+
+// parseInterval reads a refresh interval, a duration such as 1m or a number of seconds.
+func parseInterval(s string) (time.Duration, error) {
+	every, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, serr := strconv.Atoi(s)
+		if serr != nil {
+			return 0, errors.New("the interval must be a duration such as 1m, or a number of seconds.")
+		}
+		every = time.Duration(seconds) * time.Second
+	}
+	if every < minInterval {
+		return 0, fmt.Errorf("the interval must be at least %s, to go easy on the upstream site.", shortDuration(minInterval))
+	}
+	return every, nil
+}
+
+// shortDuration writes a duration without zero units. ("1m" rather than "1m0s")
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
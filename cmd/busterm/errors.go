@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/return/busterm/pkg/bus"
+)
+
+// Kinds of failure busterm tells apart, from the bus package.
+var (
+	ErrInvalidStop   = bus.ErrInvalidStop
+	ErrUpstreamDown  = bus.ErrUpstreamDown
+	ErrTimeout       = bus.ErrTimeout
+	ErrParse         = bus.ErrParse
+	ErrRateLimited   = bus.ErrRateLimited
+	ErrLayoutChanged = bus.ErrLayoutChanged
+	ErrNoDepartures  = bus.ErrNoDepartures
+)
+
+// Timeout marks network timeouts and passed deadlines as ErrTimeout.
+func Timeout(err error) error {
+	return bus.Timeout(err)
+}
+
+// Retryable reports if trying again later may work. (the upstream is down, slow or rate limited)
+func Retryable(err error) bool {
+	return bus.Retryable(err)
+}
+
+// errorKind labels an error by its kind, for reports and logs. ("upstream_down")
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrInvalidStop):
+		return "invalid_stop"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrUpstreamDown):
+		return "upstream_down"
+	case errors.Is(err, ErrNoDepartures):
+		return "no_departures"
+	case errors.Is(err, ErrLayoutChanged):
+		return "layout_changed"
+	case errors.Is(err, ErrParse):
+		return "parse_error"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	}
+	return "error"
+}
+
+// errorStatus picks the API status code and JSON error for an error.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidStop):
+		return http.StatusBadRequest, invalidNaptan
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusServiceUnavailable, busy
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout, timedOut
+	case errors.Is(err, ErrUpstreamDown):
+		return http.StatusBadGateway, upstreamDown
+	case errors.Is(err, ErrParse):
+		return http.StatusInternalServerError, unreadable
+	}
+	return http.StatusInternalServerError, unable
+}
+
+// exitCode picks the exit status for an error, so scripts can tell failures apart: 3 for an
+// invalid stop, 4 when the upstream site is down or won't fetch right now, 5 when its answer
+// can't be read, else 1. (`busterm wait` exits 2 when it gives up)
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidStop):
+		return 3
+	case Retryable(err):
+		return 4
+	case errors.Is(err, ErrParse):
+		return 5
+	}
+	return 1
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	// event types.
+	arrived  = "new"
+	changed  = "changed"
+	departed = "departed"
+
+	// events receives the watch mode events, if enabled.
+	events io.Writer
+)
+
+// Event describes how a bus changed between two refreshes of the board.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Ref      string    `json:"ref"`
+	Bus      Bus       `json:"bus"`
+	Previous *Bus      `json:"previous,omitempty"`
+}
+
+// openEvents opens where events are written: "stderr", or a file or named pipe.
+// Opening a named pipe waits until something reads from it.
+func openEvents(target string) (io.Writer, error) {
+	if target == "stderr" {
+		return os.Stderr, nil
+	}
+	return os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// emit writes events as JSON lines.
+func emit(w io.Writer, ref string, evs []Event) error {
+	encoder := json.NewEncoder(w)
+	for _, ev := range evs {
+		ev.Ref = ref
+		if err := encoder.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
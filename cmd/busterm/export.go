@@ -0,0 +1,150 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshot is a stop's board as printed for a noticeboard.
+type snapshot struct {
+	Code        string
+	Name        string
+	Printed     string
+	Filter      string
+	Departures  []Bus
+	Frequencies []frequency
+}
+
+// frequency is how often a service calls at the stop, going by the board.
+type frequency struct {
+	Service string
+	To      string
+	Buses   int
+	// Every is the average gap between its buses, or empty with only one on the board.
+	Every string
+}
+
+// printable lays a snapshot out on one A4 page, in black on white with large type, so it reads
+// from a distance and prints well on any printer. Printing it to PDF from a browser keeps the layout.
+var printable = template.Must(template.New("printable").Funcs(template.FuncMap{"at": printedTime}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Departures from {{if .Name}}{{.Name}}{{else}}{{.Code}}{{end}}</title>
+<style>
+@page { size: A4; margin: 15mm; }
+body { font-family: Helvetica, Arial, sans-serif; color: #000; background: #fff; font-size: 14pt; margin: 0 auto; max-width: 180mm; }
+h1 { font-size: 28pt; margin: 0; }
+h2 { font-size: 18pt; margin: 10mm 0 3mm; border-bottom: 2pt solid #000; }
+.stop { font-size: 14pt; margin: 2mm 0 0; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 2mm 3mm; border-bottom: 0.5pt solid #000; }
+th { font-size: 12pt; }
+tr { page-break-inside: avoid; }
+td.service { font-weight: bold; font-size: 16pt; }
+.note { font-size: 10pt; margin-top: 8mm; }
+</style>
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}}{{else}}Bus stop {{.Code}}{{end}}</h1>
+<p class="stop">Stop {{.Code}} · printed {{.Printed}}{{if .Filter}} · {{.Filter}}{{end}}</p>
+
+<h2>Next departures</h2>
+{{if .Departures}}<table>
+<thead><tr><th>Service</th><th>To</th><th>Time</th><th>Low floor</th></tr></thead>
+<tbody>
+{{range .Departures}}<tr><td class="service">{{.Service}}</td><td>{{.To}}</td><td>{{at .}}</td><td>{{if .LowFloor}}Yes{{end}}</td></tr>
+{{end}}</tbody>
+</table>{{else}}<p>No buses were due when this was printed.</p>{{end}}
+
+{{if .Frequencies}}<h2>How often</h2>
+<table>
+<thead><tr><th>Service</th><th>To</th><th>Buses</th><th>About every</th></tr></thead>
+<tbody>
+{{range .Frequencies}}<tr><td class="service">{{.Service}}</td><td>{{.To}}</td><td>{{.Buses}}</td><td>{{.Every}}</td></tr>
+{{end}}</tbody>
+</table>{{end}}
+
+<p class="note">Times are live estimates when this was printed and will have changed since.
+How often is worked out from those departures, not the full timetable.
+Check busterm or the stop's display for the latest.</p>
+</body>
+</html>
+`))
+
+// printedTime is when a bus leaves on the clock, as a countdown means nothing on paper.
+func printedTime(bus Bus) string {
+	if bus.Expected.IsZero() {
+		return bus.When()
+	}
+	return bus.Expected.Local().Format("15:04")
+}
+
+// exportHTML writes a printable snapshot of a stop's board.
+func exportHTML(w io.Writer, code string, buses []Bus, f Filter) error {
+	s := snapshot{
+		Code:        code,
+		Name:        stopName(code),
+		Printed:     clock().Format("Mon 2 Jan 2006, 15:04"),
+		Departures:  buses,
+		Frequencies: frequencies(buses),
+	}
+	if f.String() != "none" {
+		s.Filter = f.String()
+	}
+	return printable.Execute(w, s)
+}
+
+// frequencies works out how often each service on the board calls, in service order.
+func frequencies(buses []Bus) []frequency {
+	byService := map[string][]Bus{}
+	for _, bus := range buses {
+		byService[bus.Service] = append(byService[bus.Service], bus)
+	}
+	services := []string{}
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return serviceLess(services[i], services[j]) })
+
+	freqs := []frequency{}
+	for _, service := range services {
+		calls := byService[service]
+		// Where the service goes, each place once in the order they come.
+		tos := []string{}
+		for _, bus := range calls {
+			if !slices.Contains(tos, bus.To) {
+				tos = append(tos, bus.To)
+			}
+		}
+		freq := frequency{Service: service, To: strings.Join(tos, " / "), Buses: len(calls)}
+
+		// The average gap between the first and last bus with a time.
+		times := []time.Time{}
+		for _, bus := range calls {
+			if !bus.Expected.IsZero() {
+				times = append(times, bus.Expected)
+			}
+		}
+		if len(times) > 1 {
+			sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+			gap := times[len(times)-1].Sub(times[0]) / time.Duration(len(times)-1)
+			freq.Every = minutes(gap.Round(time.Minute))
+		}
+		freqs = append(freqs, freq)
+	}
+	return freqs
+}
+
+// minutes writes a gap in whole minutes. ("1 min", "12 mins")
+func minutes(d time.Duration) string {
+	if m := int(d.Minutes()); m != 1 {
+		return strconv.Itoa(m) + " mins"
+	}
+	return "1 min"
+}
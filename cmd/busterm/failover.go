@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Source is a provider to fall back on, from the [[failover]] tables of the config file.
+type Source struct {
+	// Source is one of the --source names, or "cached" for the last saved board.
+	Source string `toml:"source"`
+	// Endpoint is its URL, as with --endpoint.
+	Endpoint string `toml:"endpoint"`
+	// APIKey is its API key, as with --api-key.
+	APIKey string `toml:"api_key"`
+}
+
+// Failover tries providers in order until one answers, so a failed or slow scrape falls back
+// on another source, or on the last saved board, instead of ending with no buses at all.
+type Failover []Provider
+
+// withFailover puts the failover sources of the config file behind a provider.
+func (config Config) withFailover(primary Provider) (Provider, error) {
+	if len(config.Failover) == 0 {
+		return primary, nil
+	}
+	chain := Failover{primary}
+	for _, s := range config.Failover {
+		if s.Source == "cached" {
+			chain = append(chain, Saved{})
+			continue
+		}
+		p, err := openProvider(s.Source, s.Endpoint, s.APIKey)
+		if err != nil {
+			return nil, errors.New("failover: " + err.Error())
+		}
+		chain = append(chain, p)
+	}
+	return chain, nil
+}
+
+// fallsBackToSaved reports if the failover ends with saved boards, which need the warm file.
+func (config Config) fallsBackToSaved() bool {
+	for _, s := range config.Failover {
+		if s.Source == "cached" {
+			return true
+		}
+	}
+	return false
+}
+
+// String names the providers in order in reports. ("acis → bods → cached")
+func (f Failover) String() string {
+	names := []string{}
+	for _, p := range f {
+		names = append(names, fmt.Sprint(p))
+	}
+	return strings.Join(names, " → ")
+}
+
+// Capabilities of the chain are what any of its providers can fill in.
+func (f Failover) Capabilities() Capabilities {
+	c := Capabilities{}
+	for _, p := range f {
+		c = c.Or(capabilitiesOf(p))
+	}
+	return c
+}
+
+// CheckStop checks stops the way the first provider does.
+func (f Failover) CheckStop(ref string) error {
+	if p, ok := f[0].(stopChecker); ok {
+		return p.CheckStop(ref)
+	}
+	return checkNaptan(ref)
+}
+
+// Preconnect gets the first provider's connection ready, if it can.
+func (f Failover) Preconnect() error {
+	if p, ok := f[0].(preconnecter); ok {
+		return p.Preconnect()
+	}
+	return errors.ErrUnsupported
+}
+
+// FetchDepartures returns the departures from the first provider that answers. A stop that
+// doesn't exist won't exist elsewhere either, and one with no buses is an answer, so neither is
+// retried. When every provider fails, the first one's error is returned.
+func (f Failover) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	var first error
+	for _, p := range f {
+		buses, err := p.FetchDepartures(ctx, ref)
+		if err == nil {
+			return buses, nil
+		}
+		if first == nil {
+			first = err
+		}
+		if errors.Is(err, ErrInvalidStop) || errors.Is(err, ErrNoDepartures) || ctx.Err() != nil {
+			break
+		}
+	}
+	return []Bus{}, first
+}
+
+// Saved serves the last board saved for a stop, marked stale, as the last resort of a failover.
+type Saved struct{}
+
+// String names the provider in reports.
+func (Saved) String() string {
+	return "cached"
+}
+
+// Capabilities of saved boards: none of their own, they're old copies of another source's.
+func (Saved) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// FetchDepartures returns the newest saved board of a stop.
+func (Saved) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	return saved(ref)
+}
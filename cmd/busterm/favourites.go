@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/return/busterm/pkg/render"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// Favourite is a stop kept with `busterm fav add`, with an optional name of the user's own. ("Work")
+type Favourite struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+}
+
+// favouritesPath returns the location of the favourites file, next to the config file.
+func favouritesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "busterm", "favourites.json")
+}
+
+// loadFavourites reads the favourite stops, in the order they were added.
+func loadFavourites() ([]Favourite, error) {
+	favourites := []Favourite{}
+	data, err := os.ReadFile(favouritesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return favourites, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &favourites); err != nil {
+		return nil, errors.New(favouritesPath() + ": " + err.Error())
+	}
+	return favourites, nil
+}
+
+// saveFavourites writes the favourite stops.
+func saveFavourites(favourites []Favourite) error {
+	path := favouritesPath()
+	if path == "" {
+		return errors.New("nowhere to keep favourites: no config directory.")
+	}
+	data, err := json.MarshalIndent(favourites, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// addFavourite adds a stop to the favourites, or renames it if it's already one.
+func addFavourite(code string, name string) error {
+	favourites, err := loadFavourites()
+	if err != nil {
+		return err
+	}
+	for i, f := range favourites {
+		if f.Code == code {
+			favourites[i].Name = name
+			return saveFavourites(favourites)
+		}
+	}
+	return saveFavourites(append(favourites, Favourite{Code: code, Name: name}))
+}
+
+// removeFavourite takes a stop out of the favourites.
+func removeFavourite(code string) error {
+	favourites, err := loadFavourites()
+	if err != nil {
+		return err
+	}
+	for i, f := range favourites {
+		if f.Code == code {
+			return saveFavourites(append(favourites[:i], favourites[i+1:]...))
+		}
+	}
+	return errors.New(code + " is not a favourite.")
+}
+
+// listFavourites prints the favourite stops.
+func listFavourites(c clif.Output) error {
+	favourites, err := loadFavourites()
+	if err != nil {
+		return err
+	}
+	if len(favourites) == 0 {
+		fmt.Println("No favourites, add one with: busterm fav add <code> [<name>]")
+		return nil
+	}
+	rows := [][]string{}
+	for _, f := range favourites {
+		rows = append(rows, []string{f.Code, "<headline>" + f.Name + "<reset>", stopName(f.Code)})
+	}
+	c.Printf("%s\n", render.Columns([]string{"Code", "Name", "Stop"}, rows, 0))
+	return nil
+}
+
+// showFavourites prints the boards of every favourite stop, fetched at once.
+func showFavourites(c clif.Output) error {
+	favourites, err := loadFavourites()
+	if err != nil {
+		return err
+	}
+	if len(favourites) == 0 {
+		return errors.New("no favourites, add one with: busterm fav add <code> [<name>]")
+	}
+	refs := []string{}
+	for _, f := range favourites {
+		refs = append(refs, f.Code)
+	}
+
+	failed := false
+	for i, b := range fetchAll(context.Background(), refs) {
+		if i > 0 {
+			fmt.Println()
+		}
+		if name := favourites[i].Name; name != "" {
+			c.Printf("\r<headline>%s<reset>\n", name)
+		}
+		if b.err != nil {
+			c.Printf("Stop Ref: <headline>%s<reset>\n<error>%s<reset>\n", b.ref, b.err)
+			failed = true
+			continue
+		}
+		PrintTable(filter.Apply(b.buses), b.ref)
+	}
+	if failed {
+		return errors.New("some favourites couldn't be fetched.")
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter narrows down the buses shown on the board.
+type Filter struct {
+	// Group is the name of the destination group buses must be heading towards.
+	Group string
+	// Destinations are the destinations in the group.
+	Destinations []string
+	// Services are the services to keep. (empty = all)
+	Services []string
+	// Exclude are the services to hide, such as noisy ones at a busy interchange.
+	Exclude []string
+	// To is text the destination must contain, whatever its case. (empty = all)
+	To string
+	// Accessible keeps only low floor buses.
+	Accessible bool
+	// Within is how soon buses must be expected. (0 = any time)
+	Within time.Duration
+	// Sort orders the buses by time, service or destination. (empty = time)
+	Sort string
+	// Limit is how many buses to keep. (0 = all)
+	Limit int
+}
+
+// sortOrders are the orders --sort takes.
+var sortOrders = []string{"time", "service", "destination"}
+
+// filter is the filter applied to the board in the terminal.
+var filter Filter
+
+// Apply returns the buses that pass the filter.
+func (f Filter) Apply(buses []Bus) []Bus {
+	kept := []Bus{}
+	now := clock()
+	for _, bus := range buses {
+		if f.Group != "" && !headingTowards(bus.To, f.Destinations) {
+			continue
+		}
+		if len(f.Services) > 0 && !oneOf(bus.Service, f.Services) {
+			continue
+		}
+		if oneOf(bus.Service, f.Exclude) {
+			continue
+		}
+		if f.To != "" && !strings.Contains(strings.ToLower(bus.To), strings.ToLower(f.To)) {
+			continue
+		}
+		if f.Accessible && !bus.LowFloor {
+			continue
+		}
+		if f.Within > 0 {
+			// Buses without a time we can read can't be said to be coming soon.
+			if bus.Expected.IsZero() || bus.Expected.Sub(now) > f.Within {
+				continue
+			}
+		}
+		kept = append(kept, bus)
+	}
+
+	// Boards come soonest first. Other orders keep that within each service or destination.
+	switch f.Sort {
+	case "service":
+		sort.SliceStable(kept, func(a, b int) bool { return serviceLess(kept[a].Service, kept[b].Service) })
+	case "destination":
+		sort.SliceStable(kept, func(a, b int) bool { return strings.ToLower(kept[a].To) < strings.ToLower(kept[b].To) })
+	}
+	if f.Limit > 0 && len(kept) > f.Limit {
+		kept = kept[:f.Limit]
+	}
+	return kept
+}
+
+// requestFilter is the filter an API request asks for with ?service=36,X84, ?exclude=110,111,
+// ?dest=leeds, ?accessible=true, ?within=30m, ?sort=service and ?limit=5. Orders, windows and
+// limits that don't make sense are ignored.
+func requestFilter(r *http.Request) Filter {
+	query := r.URL.Query()
+	f := Filter{Services: splitList(query.Get("service")), Exclude: splitList(query.Get("exclude")), To: strings.TrimSpace(query.Get("dest"))}
+	f.Accessible, _ = strconv.ParseBool(query.Get("accessible"))
+	if within, err := time.ParseDuration(query.Get("within")); err == nil && within > 0 {
+		f.Within = within
+	}
+	if order := strings.ToLower(query.Get("sort")); oneOf(order, sortOrders) {
+		f.Sort = order
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		f.Limit = limit
+	}
+	return f
+}
+
+// String describes the active filters.
+func (f Filter) String() string {
+	active := []string{}
+	if f.Group != "" {
+		active = append(active, "towards "+f.Group)
+	}
+	if len(f.Services) > 0 {
+		active = append(active, "services "+strings.Join(f.Services, ", "))
+	}
+	if len(f.Exclude) > 0 {
+		active = append(active, "not "+strings.Join(f.Exclude, ", "))
+	}
+	if f.To != "" {
+		active = append(active, "to "+f.To)
+	}
+	if f.Accessible {
+		active = append(active, "low floor")
+	}
+	if f.Within > 0 {
+		active = append(active, "within "+shortDuration(f.Within))
+	}
+	if f.Sort != "" && f.Sort != "time" {
+		active = append(active, "by "+f.Sort)
+	}
+	if f.Limit > 0 {
+		active = append(active, "first "+strconv.Itoa(f.Limit))
+	}
+	if len(active) == 0 {
+		return "none"
+	}
+	return strings.Join(active, ", ")
+}
+
+// headingTowards checks if a destination matches any destination in a group.
+func headingTowards(to string, group []string) bool {
+	to = strings.ToLower(to)
+	for _, dest := range group {
+		if strings.Contains(to, strings.ToLower(normalise(dest))) {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOf checks if a service is in a list, ignoring case.
+func oneOf(service string, services []string) bool {
+	for _, s := range services {
+		if strings.EqualFold(strings.TrimSpace(s), service) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list such as "36,X84".
+func splitList(list string) []string {
+	out := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// slug turns a group name such as "City Centre" into "city-centre".
+func slug(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// group looks up a destination group by name or slug.
+func (config Config) group(name string) (string, []string, error) {
+	for group, dests := range config.Groups {
+		if slug(group) == slug(name) {
+			return group, dests, nil
+		}
+	}
+	return "", nil, errors.New("unknown destination group: " + name)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "errors"
+
+// Glyphs are the symbols used to draw buses and stops.
+type Glyphs struct {
+	// Set is the name of a built-in glyph set to start from.
+	Set          string `toml:"set"`
+	Bus          string `toml:"bus"`
+	DoubleDecker string `toml:"double_decker"`
+	Stop         string `toml:"stop"`
+}
+
+var (
+	// glyphSets bundled with busterm.
+	glyphSets = map[string]Glyphs{
+		// Until a double decker bus is introduced into the unicode standard,
+		// the minibus will suffice.
+		"emoji": {Bus: "🚌", DoubleDecker: "🚐", Stop: "🚏"},
+		// Material Design icons from Nerd Fonts. (nf-md-bus, nf-md-bus_double_decker, nf-md-bus_stop)
+		"nerdfont": {Bus: "\U000F00E7", DoubleDecker: "\U000F079D", Stop: "\U000F1012"},
+		// Plain letters for terminals without either.
+		"ascii": {Bus: "B", DoubleDecker: "D", Stop: "S"},
+	}
+
+	// glyphs used on the board.
+	glyphs = glyphSets["emoji"]
+)
+
+// resolve fills in any glyphs not set by the user from the chosen set.
+func (g Glyphs) resolve() (Glyphs, error) {
+	name := g.Set
+	if name == "" {
+		name = "emoji"
+	}
+	set, ok := glyphSets[name]
+	if !ok {
+		return g, errors.New("unknown glyph set: " + name)
+	}
+
+	if g.Bus == "" {
+		g.Bus = set.Bus
+	}
+	if g.DoubleDecker == "" {
+		g.DoubleDecker = set.DoubleDecker
+	}
+	if g.Stop == "" {
+		g.Stop = set.Stop
+	}
+	return g, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+)
+
+// GTFSRT reads departures from a GTFS-Realtime TripUpdates feed, which many operators
+// worldwide publish. Stops are GTFS stop_ids rather than NapTAN codes.
+type GTFSRT struct {
+	// Feed is the URL or file of the TripUpdates feed.
+	Feed string
+	// HTTPClient fetches the feed, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (g GTFSRT) String() string {
+	return "gtfs-rt"
+}
+
+// Capabilities of GTFS-Realtime: predicted times only.
+func (g GTFSRT) Capabilities() Capabilities {
+	return Capabilities{Realtime: true}
+}
+
+// CheckStop accepts any GTFS stop_id, as long as it's one word.
+func (g GTFSRT) CheckStop(ref string) error {
+	if ref == "" || len(ref) > 64 || strings.ContainsAny(ref, " \t\r\n/?#&") {
+		return fmt.Errorf("%w: %q is not a <error>GTFS stop_id.<reset>\n", ErrInvalidStop, ref)
+	}
+	return nil
+}
+
+// FetchDepartures reads the feed and returns the trips calling at a stop.
+func (g GTFSRT) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	data, err := g.read(ctx)
+	if err != nil {
+		return []Bus{}, err
+	}
+	var feed gtfs.FeedMessage
+	if err := proto.Unmarshal(data, &feed); err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+
+	now := clock()
+	departures := []departure{}
+	for _, entity := range feed.GetEntity() {
+		update := entity.GetTripUpdate()
+		if update == nil || entity.GetIsDeleted() ||
+			update.GetTrip().GetScheduleRelationship() == gtfs.TripDescriptor_CANCELED {
+			continue
+		}
+		stops := update.GetStopTimeUpdate()
+		for _, stop := range stops {
+			if stop.GetStopId() != ref || stop.GetScheduleRelationship() == gtfs.TripUpdate_StopTimeUpdate_SKIPPED {
+				continue
+			}
+			// Without the static timetable only absolute times can be used.
+			at := stop.GetDeparture().GetTime()
+			if at == 0 {
+				at = stop.GetArrival().GetTime()
+			}
+			if at == 0 || time.Unix(at, 0).Before(now.Add(-time.Minute)) {
+				continue
+			}
+			// TripUpdates don't carry headsigns, so the last stop the update knows of stands in for it.
+			bus := Bus{
+				Service: update.GetTrip().GetRouteId(),
+				To:      stops[len(stops)-1].GetStopId(),
+			}
+			if label := update.GetVehicle().GetLabel(); bus.Service == "" && label != "" {
+				bus.Service = label
+			}
+			bus.DepartsAt(time.Unix(at, 0), false, now)
+			departures = append(departures, departure{bus, time.Unix(at, 0)})
+		}
+	}
+	return soonestFirst(departures), nil
+}
+
+// read fetches the feed from its URL, or reads it from a file.
+func (g GTFSRT) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(g.Feed, "http://") && !strings.HasPrefix(g.Feed, "https://") {
+		data, err := os.ReadFile(g.Feed)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+		}
+		return data, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", g.Feed, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-protobuf")
+	res, err := orUpstream(g.HTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 429:
+		return nil, fmt.Errorf("%w: the feed says %s", ErrRateLimited, res.Status)
+	case res.StatusCode != 200:
+		return nil, fmt.Errorf("%w: status != 200: status:%s", ErrUpstreamDown, res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// IRCConfig holds the server and nick the IRC bot uses.
+type IRCConfig struct {
+	// Server is the host and port to connect to. (irc.libera.chat:6697)
+	Server string `toml:"server"`
+	// TLS connects with TLS, as most networks expect on 6697.
+	TLS bool `toml:"tls"`
+	// Nick is the bot's nickname.
+	Nick string `toml:"nick"`
+	// Password is the server password, sent with PASS. Prefer "${IRC_PASSWORD}" or "file:".
+	Password string `toml:"password"`
+	// Channels are the channels to join. (#leeds-buses)
+	Channels []string `toml:"channels"`
+}
+
+// IRC is a bot that answers `!bus <code|alias>` in its channels and private messages with a
+// one line summary of the stop's board.
+type IRC struct {
+	config IRCConfig
+	boards *Boards
+	nick   string
+}
+
+// NewIRC sets up the bot to answer with boards, checking the config.
+func NewIRC(config IRCConfig, boards *Boards) (*IRC, error) {
+	if config.Server == "" || config.Nick == "" {
+		return nil, errors.New("the IRC bot needs server and nick in the [irc] table of the config file.")
+	}
+	return &IRC{config: config, boards: boards}, nil
+}
+
+// Run answers messages until busterm is stopped, reconnecting when the connection drops.
+func (b *IRC) Run() {
+	for {
+		if err := b.session(); err != nil {
+			log.Println("irc:", err)
+		}
+		time.Sleep(botRetry)
+	}
+}
+
+// session connects, registers, joins the channels and answers messages until the connection drops.
+func (b *IRC) session() error {
+	var conn net.Conn
+	var err error
+	if b.config.TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", b.config.Server, nil)
+	} else {
+		conn, err = net.DialTimeout("tcp", b.config.Server, 30*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	send := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	b.nick = b.config.Nick
+	if b.config.Password != "" {
+		send("PASS %s", b.config.Password)
+	}
+	send("NICK %s", b.nick)
+	send("USER %s 0 * :busterm", b.config.Nick)
+
+	lines := bufio.NewScanner(conn)
+	for {
+		// Servers ping every few minutes, so a silent connection is a dead one.
+		conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+		if !lines.Scan() {
+			if lines.Err() != nil {
+				return lines.Err()
+			}
+			return errors.New("the server closed the connection")
+		}
+		prefix, command, params := parseIRC(lines.Text())
+		switch command {
+		case "PING":
+			send("PONG :%s", strings.Join(params, " "))
+		case "001":
+			// Registered, so the channels can be joined.
+			for _, channel := range b.config.Channels {
+				send("JOIN %s", channel)
+			}
+		case "433":
+			// The nick is taken.
+			b.nick += "_"
+			send("NICK %s", b.nick)
+		case "PRIVMSG":
+			if len(params) < 2 {
+				continue
+			}
+			sender, _, _ := strings.Cut(prefix, "!")
+			// Answer in the channel, or privately to private messages.
+			target := params[0]
+			if strings.EqualFold(target, b.nick) {
+				target = sender
+			}
+			if reply, ok := botCommand(b.boards, params[1], summaryReply); ok {
+				send("PRIVMSG %s :%s", target, strings.Join(strings.Fields(reply), " "))
+			}
+		case "ERROR":
+			return errors.New("the server says " + strings.Join(params, " "))
+		}
+	}
+}
+
+// parseIRC splits a line from the server into its prefix, command and parameters, the last of
+// which may have spaces. (":nick!user@host PRIVMSG #leeds :!bus 45010687")
+func parseIRC(line string) (string, string, []string) {
+	prefix := ""
+	if strings.HasPrefix(line, ":") {
+		prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	params := strings.Fields(line)
+	if len(params) == 0 {
+		return prefix, "", nil
+	}
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return prefix, strings.ToUpper(params[0]), params[1:]
+}
+
+// summaryReply answers with the stop and its summary, short enough for one IRC line.
+func summaryReply(code string, buses []Bus) string {
+	return code + ": " + summary(filter.Apply(buses))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier is how hard the upstream site is struggling, and so how much busterm holds back.
+type Tier int
+
+const (
+	// normal serves everything as usual.
+	normal Tier = iota
+	// strained keeps boards twice as long and leaves weather warnings off.
+	strained
+	// overloaded keeps boards four times as long and leaves weather warnings off.
+	overloaded
+)
+
+// String names a tier in the X-Busterm-Load header.
+func (t Tier) String() string {
+	switch t {
+	case strained:
+		return "strained"
+	case overloaded:
+		return "overloaded"
+	}
+	return "normal"
+}
+
+var (
+	// loadWindow is how many recent fetches the tier is worked out from.
+	loadWindow = 50
+
+	// Fetches slower than these, on the median, or failing more often, strain the upstream site.
+	strainedLatency   = 2 * time.Second
+	strainedErrors    = 0.2
+	overloadedLatency = 5 * time.Second
+	overloadedErrors  = 0.5
+)
+
+// Load keeps track of how recent fetches from the upstream site went, so busterm can shed load
+// when it struggles rather than failing outright.
+type Load struct {
+	mu      sync.Mutex
+	fetches []fetch
+}
+
+// fetch is how long a fetch took and whether the upstream site failed it.
+type fetch struct {
+	took   time.Duration
+	failed bool
+}
+
+// load tracks every fetch from the upstream site.
+var load = &Load{}
+
+// Observe records a fetch. Only failures worth retrying count against the upstream site; a stop
+// that doesn't exist isn't its fault.
+func (l *Load) Observe(took time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fetches = append(l.fetches, fetch{took, err != nil && Retryable(err)})
+	if len(l.fetches) > loadWindow {
+		l.fetches = l.fetches[len(l.fetches)-loadWindow:]
+	}
+}
+
+// Tier works out how hard the upstream site is struggling from the recent fetches.
+func (l *Load) Tier() Tier {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.fetches) == 0 {
+		return normal
+	}
+	took := []time.Duration{}
+	failed := 0
+	for _, f := range l.fetches {
+		took = append(took, f.took)
+		if f.failed {
+			failed++
+		}
+	}
+	sort.Slice(took, func(a, b int) bool { return took[a] < took[b] })
+	median := took[len(took)/2]
+	errors := float64(failed) / float64(len(l.fetches))
+	switch {
+	case median > overloadedLatency || errors > overloadedErrors:
+		return overloaded
+	case median > strainedLatency || errors > strainedErrors:
+		return strained
+	}
+	return normal
+}
+
+// Stretch lengthens how long boards are kept while the upstream site struggles.
+func (l *Load) Stretch(ttl time.Duration) time.Duration {
+	switch l.Tier() {
+	case strained:
+		return 2 * ttl
+	case overloaded:
+		return 4 * ttl
+	}
+	return ttl
+}
+
+// Annotate tells clients when busterm is holding back, in the X-Busterm-Load header.
+func (l *Load) Annotate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tier := l.Tier(); tier != normal {
+			w.Header().Set("X-Busterm-Load", tier.String())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,1136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docopt/docopt-go"
+	"github.com/return/busterm/pkg/acis"
+	"github.com/return/busterm/pkg/bus"
+	"github.com/return/busterm/pkg/render"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+var usage = `busterm
+
+View all the NapTAN buses directly in realtime in the terminal!
+
+Usage:
+	busterm show <code> [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--profile <name>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--offline] [--config <file>]
+	busterm watch <code> [<interval>] [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--record <file>] [--emit-events <target>] [--output <target>] [--profile <name>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--offline] [--config <file>]
+	busterm api [--public] [--port <port>] [--preflight [--lenient]] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--offline] [--config <file>]
+	busterm replay <history> [--speed <x>] [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--emit-events <target>] [--output <target>] [--profile <name>] [--config <file>] [-a | --api]
+	busterm remote --server <url> [-t] (-n | --naptan) <code> [<interval>] [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--profile <name>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm wait (-n | --naptan) <code> [--service <list>] [--until-due] [--timeout <duration>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm schedule [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm export html (-n | --naptan) <code> [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm bench --stops <file> [--concurrency <n>] [--rounds <n>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm soak --stops <file> [--duration <duration>] [--concurrency <n>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm mock-upstream [--scenario <name>] [--delay <duration>] [--port <port>]
+	busterm auth (set | rm) <provider> [--config <file>]
+	busterm [-t] [--profile <name>] [--config <file>]
+	busterm auth list [--config <file>]
+	busterm fav add <code> [<name>] [--config <file>]
+	busterm fav rm <code> [--config <file>]
+	busterm fav list [--config <file>]
+	busterm fav show [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--profile <name>] [--config <file>]
+	busterm bot (--matrix | --irc) [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--profile <name>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--config <file>]
+	busterm providers list [--source <name>] [--endpoint <url>] [--config <file>]
+	busterm stops <code>
+	busterm search <query> [--limit <n>]
+	busterm near <postcode>... [--limit <n>]
+	busterm [-t] (-n | --naptan) <code> [<interval>] [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--record <file>] [--emit-events <target>] [--output <target>] [--profile <name>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--offline] [--config <file>]
+	busterm (-a | --api) [--public] [--port <port>] [--base-url <url>] [--region <name>] [--source <name>] [--endpoint <url>] [--api-key <key>] [--fetch-timeout <duration>] [--retries <n>] [--offline] [--config <file>]
+	busterm --simulate <file> [--speed <x>] [--towards <group>] [--service <list>] [--exclude <list>] [--to <text>] [--within <duration>] [--accessible] [--limit <n>] [--sort <order>] [--emit-events <target>] [--output <target>] [--profile <name>] [--config <file>] [-a | --api]
+	busterm -h | --help
+	busterm --version
+
+Options:
+	-h --help               Show this screen.
+	--version               Show version.
+	--record <file>         Append every refresh to a history file.
+	--emit-events <target>  Write new/changed/departed events as JSON lines to stderr or a file/named pipe in watch mode.
+	--output <target>       Send each refresh to fifo:<path> or unix:<path> as JSON, or to a sign on serial:<device> in watch mode.
+	--service <list>        Only buses on these services, comma separated. (36,X84)
+	--until-due             Wait until the bus is due rather than just on the board.
+	--timeout <duration>    Give up waiting after this long, exiting with status 2. (30m)
+	--stops <file>          File of stop codes to benchmark or soak test, one per line.
+	--duration <duration>   How long to soak test for. [default: 1h]
+	--concurrency <n>       How many stops to fetch at once. [default: 8]
+	--rounds <n>            How many times to fetch every stop. [default: 1]
+	--limit <n>             How many stops or departures to list. (search and near: 10)
+	--sort <order>          Order departures by time, service or destination. (default: time)
+	--base-url <url>        Departure page of the upstream site. (http://yorkshire.acisconnect.com/Text/WebDisplay.aspx)
+	--region <name>         ACIS Connect region: yorkshire, cambridgeshire or kent. (default: yorkshire)
+	--source <name>         Where departures come from: acis, siri, gtfs-rt, bods, tfl or remote. [default: acis]
+	--endpoint <url>        URL of the source, such as a SIRI-SM service. (gtfs-rt: URL or file of the feed)
+	--api-key <key>         API key of the source. (bods or remote, else from busterm auth set)
+	--fetch-timeout <duration>  How long a fetch from the source may take, 0 for no limit. (default: 15s, --public: 8s)
+	--retries <n>           Try fetches that fail for a while this many more times, backing off. [default: 0]
+	--server <url>          busterm API to fetch departures from. (https://bus.example.com)
+	--scenario <name>       Mock upstream scenario: normal, slow, empty or garbage. [default: normal]
+	--delay <duration>      How long the slow scenario takes to answer. [default: 10s]
+	--port <port>           Port to listen on. (API: 7654, mock upstream: 7655)
+	--offline               Only show saved boards, labelled with their age, never touching the network.
+	--public                Harden the API for exposing it to the internet.
+	--preflight             Check the upstream site, cache backend and stops list before serving, exiting on the first failure.
+	--lenient               Warn about failed preflight checks and serve without what failed.
+	--matrix                Answer !bus <code> in Matrix rooms, as the account in the config file.
+	--irc                   Answer !bus <code> in IRC channels with a one line summary, as the nick in the config file.
+	--simulate <file>       Replay a recorded history file.
+	--speed <x>             Replay speed. [default: 10x]
+	--towards <group>       Only show buses heading to a destination group from the config file.
+	--exclude <list>        Hide buses on these services, comma separated. (110,111)
+	--to <text>             Only show buses whose destination contains this. (leeds)
+	--within <duration>     Only show buses expected within this long. (30m)
+	--accessible            Only show low floor buses, for wheelchairs and buggies.
+	--profile <name>        Render profile: default, tv, phone-ssh, statusbar or one from the config file.
+	--config <file>         Config file. (default: ~/.config/busterm/config.toml)`
+
+var (
+	// json errors.
+	unable        = `{"error":"unable to fetch buses."}`
+	invalidNaptan = `{"error":"NapTAN code must be an 8 digit number."}`
+	tooMany       = `{"error":"too many requests."}`
+	busy          = `{"error":"too busy to fetch new stops, try again shortly."}`
+	forbidden     = `{"error":"forbidden."}`
+	unauthorized  = `{"error":"missing or unknown API key."}`
+	overQuota     = `{"error":"API key quota used up."}`
+	noKeys        = `{"error":"API keys are not enabled."}`
+	badNote       = `{"error":"a note must be 1 to 140 characters."}`
+	upstreamDown  = `{"error":"the upstream site is down, try again shortly."}`
+	timedOut      = `{"error":"the upstream site took too long to answer, try again shortly."}`
+	unreadable    = `{"error":"unable to read the upstream site."}`
+
+	// refreshEvery is how often watch mode refreshes the board, shown in its heading.
+	refreshEvery time.Duration
+
+	// baseurl of the default provider.
+	baseurl = acis.DefaultURL
+
+	// length of the road drawn by PrintBus and how far ahead it reaches by default.
+	roadLength    = 12
+	defaultWindow = time.Hour
+
+	// basic input validation. (unwanted characters in haystack)
+	unwantedRunes = "aAbBcCdDeEfFgGhHiIjJkKlLmMnNoOpPqQrRsStTuUvVwWxXyYzZ;:\\'\"{[}]\\|+=-_)(*&^%$#@!~`<>?"
+)
+
+// Bus is a departure, as the bus package has it.
+type Bus = bus.Bus
+
+// getBuses fetches an array of buses for a stop and tidies them up for display, giving up when
+// ctx is done.
+func getBuses(ctx context.Context, ref string) ([]Bus, error) {
+	var buses []Bus
+	var err error
+
+	// Only use saved boards when offline. They're already tidied up.
+	if offline && replay == nil {
+		return saved(ref)
+	}
+
+	// Serve recorded buses while simulating.
+	if replay != nil {
+		buses, err = replay.Buses(ref)
+	} else {
+		buses, err = fetchRetrying(ctx, ref)
+	}
+	// A stop with no buses has an empty board.
+	if errors.Is(err, ErrNoDepartures) {
+		buses, err = []Bus{}, nil
+	}
+	if err != nil {
+		return buses, err
+	}
+
+	// Expand abbreviated destinations and rename services.
+	now := clock()
+	for i := range buses {
+		buses[i].To = normalise(buses[i].To)
+		buses[i].Service = rename(buses[i].Service)
+		// Histories recorded before buses had times of their own only have the board's text.
+		if buses[i].Expected.IsZero() {
+			buses[i].ReadTime(buses[i].Time, now)
+		}
+	}
+	// Soonest first, the same way whatever the source.
+	sortBuses(buses)
+
+	// A saved board from a failover is already tidied up, and isn't new.
+	if isStale(buses) {
+		return buses, nil
+	}
+
+	// Keep the board for the next start, unless it's a recording.
+	if warm != nil && replay == nil {
+		warm.Remember(ref, buses)
+	}
+	return buses, nil
+}
+
+// serveBoard renders the board for a stop the same way the terminal does and sends it as text.
+func (s *Server) serveBoard(ctx context.Context, w http.ResponseWriter, code string, f Filter, output func(io.Writer) *clif.DefaultOutput) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	code = resolveAlias(code)
+
+	err := checkCode(code)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, "NapTAN code must be an 8 digit number.")
+		return
+	}
+
+	buses, err := s.lookup(ctx, code)
+	if err != nil {
+		if Retryable(err) {
+			w.Header().Set("Retry-After", "30")
+		}
+		status, _ := errorStatus(err)
+		w.WriteHeader(status)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	w.WriteHeader(200)
+	io.WriteString(w, boardText(output, f, buses, code))
+}
+
+// boardText renders a board the way the terminal shows it, dropping the carriage returns meant
+// for redrawing one.
+func boardText(output func(io.Writer) *clif.DefaultOutput, f Filter, buses []Bus, code string) string {
+	var board bytes.Buffer
+	renderTable(output(&board), display, f, f.Apply(buses), code)
+	return strings.ReplaceAll(board.String(), "\r", "")
+}
+
+// PrintBus prints an estimated measure of how close the bus is from the bus stop.
+//
+// The road is roadLength segments long and covers the profile's window (an hour by default),
+// so each segment is window/roadLength minutes. With the defaults every "_" is 5 minutes and
+// "🚏__🚌__________" is a bus 10 minutes away. Buses beyond the window wait at the far end.
+func PrintBus(b Bus) string {
+	return road(display, b)
+}
+
+// road draws the emoji road for a bus using a profile's window.
+func road(p Profile, b Bus) string {
+	window := defaultWindow
+	if p.Window > 0 {
+		window = time.Duration(p.Window) * time.Minute
+	}
+	r := render.Road{Bus: glyphs.Bus, DoubleDecker: glyphs.DoubleDecker, Stop: glyphs.Stop, Length: roadLength, Window: window}
+	return r.Draw(b, clock())
+}
+
+// PrintTable prints the timetable to the screen using the active display profile.
+func PrintTable(bus []Bus, ref string) {
+	var c clif.Output = clif.NewColorOutput(os.Stdin)
+	if display.Monochrome {
+		c = clif.NewMonochromeOutput(os.Stdin)
+	}
+	renderTable(c, display, filter, bus, ref)
+}
+
+// renderTable writes the timetable to an output using a profile. The filter is only described in the footer.
+func renderTable(c clif.Output, p Profile, f Filter, bus []Bus, ref string) {
+	// Only show as many departures as the display has room for.
+	if p.Rows > 0 && len(bus) > p.Rows {
+		bus = bus[:p.Rows]
+	}
+
+	switch p.Layout {
+	case "oneline":
+		c.Printf("\r%s\n", oneline(bus))
+		return
+	case "large":
+		c.Printf("\r%s", large(bus))
+		return
+	}
+
+	// Headers and Rows.
+	headers := []string{"Bus", "To", "Time", "Emoji", "Low Floor"}
+	if p.Compact {
+		headers = []string{"Bus", "To", "Time"}
+	}
+	// Name the operators when the provider knows them. (BODS does, the scraper doesn't)
+	operators := false
+	for _, b := range bus {
+		operators = operators || b.Operator != ""
+	}
+	if operators && !p.Compact {
+		headers = append(headers, "Operator")
+	}
+	rows := [][]string{}
+	// Loop over the Buses and append them to the rows.
+	for _, b := range bus {
+		// Show how much the estimate has moved around, if we know.
+		when := b.When()
+		switch b.Confidence {
+		case steady:
+			when += " <success>" + steady + "<reset>"
+		case volatile:
+			when += " <error>" + volatile + "<reset>"
+		}
+		// Boards saved at the last shutdown are shown until fresh ones arrive.
+		if b.Stale {
+			when += " <warn>" + staleness(b.Fetched) + "<reset>"
+		}
+		s := []string{
+			b.Service,
+			"<warn>" + b.To + "<reset>",
+			when,
+		}
+		if !p.Compact {
+			s = append(s, road(p, b), strconv.FormatBool(b.LowFloor))
+			if operators {
+				s = append(s, b.Operator)
+			}
+		}
+		rows = append(rows, s)
+	}
+	// Lay the columns out by how wide cells are on screen, so emoji and colours don't skew them.
+	rendered := render.Columns(headers, rows, p.Width)
+
+	// Parse current time in simple form. (3:04PM)
+	now := clock().Format(time.Kitchen)
+	// Print the timetable with time and stop reference.
+	// Name the stop when NaPTAN knows it.
+	// In watch mode, say how often it's refreshed.
+	every := ""
+	if refreshEvery > 0 {
+		every = ", every " + shortDuration(refreshEvery)
+	}
+	if name := stopName(ref); name != "" {
+		c.Printf("\rDeparture information for <headline>%s<reset> at <query>%s<reset>%s\n", name, now, every)
+	} else {
+		c.Printf("\rDeparture information for at " + "<query>" + now + "<reset>" + every + "\n")
+	}
+	// Show what locals have noted about the stop.
+	for _, note := range notes.For(ref) {
+		c.Printf("\r<info>✎ %s<reset>\n", note)
+	}
+	// Warn of weather that will hold buses up, unless the upstream site is struggling.
+	if weather != nil && load.Tier() == normal {
+		if warning := weather.Warning(ref); warning != "" {
+			c.Printf("\r<warn>⚠ %s<reset>\n", warning)
+		}
+	}
+	if !p.Compact {
+		c.Printf("\r\nLegend: \n%s : Bus Stop \n%s : Normal Bus\n%s : Double Decker Bus\n", glyphs.Stop, glyphs.Bus, glyphs.DoubleDecker)
+	}
+	c.Printf("\rStop Ref: <headline>%s<reset>\n\n%s\n", ref, rendered)
+	c.Printf("\r%s\n", footer(bus, f))
+}
+
+// footer summarises the board: how many buses, the soonest one, low floor buses and filters.
+func footer(bus []Bus, f Filter) string {
+	lowFloor := 0
+	soonest := -1
+	var soonestAt time.Time
+	for i, b := range bus {
+		if b.LowFloor {
+			lowFloor++
+		}
+		if !b.Expected.IsZero() && (soonest == -1 || b.Expected.Before(soonestAt)) {
+			soonest, soonestAt = i, b.Expected
+		}
+	}
+
+	departures := fmt.Sprintf("%d departures", len(bus))
+	if len(bus) == 1 {
+		departures = "1 departure"
+	}
+	parts := []string{departures}
+	if soonest != -1 {
+		b := bus[soonest]
+		parts = append(parts, fmt.Sprintf("soonest %s to %s (%s)", b.Service, b.To, b.When()))
+	}
+	parts = append(parts, fmt.Sprintf("%d low floor", lowFloor))
+	parts = append(parts, "filters: "+f.String())
+	return strings.Join(parts, " · ")
+}
+
+// oneline summarises the buses on a single line, for status bars.
+func oneline(bus []Bus) string {
+	if len(bus) == 0 {
+		return "No buses"
+	}
+	parts := []string{}
+	for _, b := range bus {
+		part := fmt.Sprintf("%s %s %s", b.Service, b.To, b.When())
+		if b.Stale {
+			part += " (" + staleness(b.Fetched) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// large renders each bus with its service and time in the block font, for TVs.
+func large(bus []Bus) string {
+	var str string
+	for _, b := range bus {
+		str += big(b.Service+" "+b.When()) + "\n" + strings.ToUpper(b.To)
+		if b.Stale {
+			str += " (" + staleness(b.Fetched) + ")"
+		}
+		str += "\n\n"
+	}
+	return str
+}
+
+// watch refreshes the timetables for one or more stops on an interval, optionally recording each refresh.
+func watch(refs []string, every time.Duration, history string) {
+	c := clif.NewColorOutput(os.Stdin)
+	refreshEvery = every
+	fmt.Print("\033[2J")
+	// Get a connection to the upstream site ready while the screen is set up.
+	if p, ok := provider.(preconnecter); ok && replay == nil && !offline {
+		go p.Preconnect()
+	}
+	// Keep track of how steady each estimate is between refreshes, for every stop.
+	trackers := map[string]*Tracker{}
+	for _, ref := range refs {
+		trackers[ref] = NewTracker()
+	}
+	// Show the boards saved at the last shutdown while the first ones are fetched.
+	if warm != nil && !offline {
+		c.Printf("\033[1;1H")
+		shown := false
+		for _, ref := range refs {
+			if buses, ok := warm.Stale(ref); ok {
+				PrintTable(filter.Apply(buses), ref)
+				shown = true
+			}
+		}
+		if shown {
+			fmt.Printf("\rUpdating...")
+		}
+	}
+	for {
+		boards := fetchAll(context.Background(), refs)
+		for i := range boards {
+			b := &boards[i]
+			if b.err != nil {
+				continue
+			}
+			// Record the board before it's annotated.
+			if history != "" {
+				if err := record(history, b.ref, b.buses, clock()); err != nil {
+					c.Printf("<error>%s<reset>\n", err)
+					os.Exit(1)
+				}
+			}
+			b.buses = filter.Apply(b.buses)
+			changes := trackers[b.ref].Observe(b.buses, clock())
+			// Send the changes downstream alongside the table.
+			if events != nil {
+				if err := emit(events, b.ref, changes); err != nil {
+					c.Printf("<error>%s<reset>\n", err)
+					os.Exit(1)
+				}
+			}
+			// Hand the board to other local processes.
+			if sink != nil {
+				if err := sink.Send(Snapshot{Time: clock(), Ref: b.ref, Buses: b.buses}); err != nil {
+					c.Printf("<error>%s<reset>\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		// A single stop keeps the whole screen, a page at a time if it doesn't fit.
+		if len(boards) == 1 {
+			b := boards[0]
+			// Keep watching through upstream outages, trying again at the next refresh.
+			if Retryable(b.err) {
+				c.Printf("\r<warn>%s, retrying in %s<reset>\033[K", b.err, every)
+				time.Sleep(every)
+				continue
+			}
+			if b.err != nil {
+				c.Printf("<error>%s<reset>\n", b.err)
+				os.Exit(1)
+			}
+			// Clear the screen and print table, a page at a time if it doesn't fit.
+			// Remove any previous messages and wait for the next refresh.
+			showPages(c, b.buses, b.ref, every)
+			fmt.Printf("\rUpdating...")
+			continue
+		}
+
+		// Several stops are shown one under the other. A stop that's down says so in its place.
+		c.Printf("\033[1;1H\033[J")
+		for _, b := range boards {
+			switch {
+			case Retryable(b.err):
+				c.Printf("\rStop Ref: <headline>%s<reset>\n<warn>%s, retrying in %s<reset>\n\n", b.ref, b.err, every)
+			case b.err != nil:
+				c.Printf("<error>%s: %s<reset>\n", b.ref, b.err)
+				os.Exit(1)
+			default:
+				PrintTable(b.buses, b.ref)
+				fmt.Println()
+			}
+		}
+		time.Sleep(every)
+		fmt.Printf("\rUpdating...")
+	}
+}
+
+// aliases are the user's names for stops, from the config file. ("home" = "45010687")
+var aliases = map[string]string{}
+
+// addAliases merges the user's stop aliases. Names match whatever their case.
+func addAliases(names map[string]string) {
+	for name, code := range names {
+		aliases[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(code)
+	}
+}
+
+// resolveAlias returns the stop an alias names, or the code as it is when it's not an alias.
+func resolveAlias(code string) string {
+	if to, ok := aliases[strings.ToLower(code)]; ok {
+		return to
+	}
+	return code
+}
+
+// checkCode checks if the stop code, or the stop its alias names, is valid. Providers with other
+// kinds of stop codes check their own.
+func checkCode(code string) error {
+	code = resolveAlias(code)
+	if p, ok := provider.(stopChecker); ok {
+		return p.CheckStop(code)
+	}
+	return checkNaptan(code)
+}
+
+// limitOption reads --limit, or returns the fallback when it isn't given.
+func limitOption(arguments map[string]interface{}, fallback int) (int, error) {
+	s, ok := arguments["--limit"].(string)
+	if !ok {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, errors.New("--limit must be a positive number.")
+	}
+	return limit, nil
+}
+
+// checkNaptan checks if the NapTAN is valid.
+func checkNaptan(code string) error {
+	if len(code) != 8 || strings.ContainsAny(code, unwantedRunes) {
+		return fmt.Errorf("%w: NapTAN code must be an <error>8 digit number.<reset>\n", ErrInvalidStop)
+	}
+	return nil
+}
+
+func main() {
+	// Parse arguments.
+	c := clif.NewColorOutput(os.Stdin)
+	arguments, _ := docopt.Parse(usage, nil, true, "busterm", false)
+	// The subcommands are the original flags by other names, which still work.
+	switch {
+	case arguments["show"] == true && arguments["fav"] != true:
+		arguments["-n"] = true
+	case arguments["watch"] == true:
+		arguments["-n"], arguments["-t"] = true, true
+	case arguments["api"] == true:
+		arguments["-a"] = true
+	case arguments["replay"] == true:
+		arguments["--simulate"] = arguments["<history>"]
+	}
+
+	// Load the config file.
+	path, ok := arguments["--config"].(string)
+	if !ok {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+	// Data files can be swapped without rebuilding.
+	if config.Assets != "" {
+		assetDir = config.Assets
+	}
+	// Stops can go by the user's own names.
+	addAliases(config.Aliases)
+	// Flags not given default to the environment's, then the config file's.
+	if err := config.applyDefaults(arguments, os.Args[1:], os.Environ()); err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+
+	// Look a stop up in NaPTAN.
+	if arguments["stops"] == true {
+		if err := describeStop(c, resolveAlias(arguments["<code>"].(string))); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Find stops by name.
+	if arguments["search"] == true {
+		limit, err := limitOption(arguments, 10)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		if err := printSearch(c, arguments["<query>"].(string), limit); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Find the stops near a postcode.
+	if arguments["near"] == true {
+		limit, err := limitOption(arguments, 10)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		postcode := strings.Join(arguments["<postcode>"].([]string), " ")
+		if err := printNear(c, postcode, limit); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Manage provider credentials.
+	if config.Credentials != nil {
+		credentials = config.Credentials
+	}
+	if arguments["auth"] == true {
+		provider, _ := arguments["<provider>"].(string)
+		switch {
+		case arguments["set"] == true:
+			err = setCredential(provider)
+		case arguments["rm"] == true:
+			err = removeCredential(provider)
+		default:
+			listCredentials()
+		}
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Add the user's destination substitutions and service names.
+	addDestinations(config.Destinations)
+	addServices(config.Services)
+
+	// Load the notes about stops.
+	notes, err = LoadNotes(notesPath(), config.Notes)
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+
+	// Check the weather at the stop.
+	if config.Weather {
+		weather = NewWeather()
+	}
+
+	// Pick the glyphs for buses and stops.
+	glyphs, err = config.Glyphs.resolve()
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+
+	// Only show buses heading towards a destination group.
+	if name, ok := arguments["--towards"].(string); ok {
+		group, dests, err := config.group(name)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		filter = Filter{Group: group, Destinations: dests}
+	}
+	// Or only buses on some services.
+	if list, ok := arguments["--service"].(string); ok {
+		filter.Services = splitList(list)
+	}
+	// Or not on some services.
+	if list, ok := arguments["--exclude"].(string); ok {
+		filter.Exclude = splitList(list)
+	}
+	// Or only buses going somewhere.
+	if to, ok := arguments["--to"].(string); ok {
+		filter.To = to
+	}
+	// Or only low floor buses.
+	filter.Accessible = arguments["--accessible"] == true
+	// Or only buses coming soon.
+	if within, ok := arguments["--within"].(string); ok {
+		filter.Within, err = time.ParseDuration(within)
+		if err != nil || filter.Within <= 0 {
+			c.Printf("<error>--within must be a duration such as 30m.<reset>\n")
+			os.Exit(1)
+		}
+	}
+	// And only so many, in some order.
+	filter.Limit, err = limitOption(arguments, 0)
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+	if order, ok := arguments["--sort"].(string); ok {
+		if !oneOf(order, sortOrders) {
+			c.Printf("<error>--sort must be one of %s.<reset>\n", strings.Join(sortOrders, ", "))
+			os.Exit(1)
+		}
+		filter.Sort = strings.ToLower(order)
+	}
+
+	// Send watch mode events somewhere.
+	if target, ok := arguments["--emit-events"].(string); ok {
+		events, err = openEvents(target)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Send every refresh to a pipe or socket.
+	if target, ok := arguments["--output"].(string); ok {
+		sink, err = openSink(target)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Pick the render profile. The config file may override the default one too.
+	name, ok := arguments["--profile"].(string)
+	if !ok {
+		name = "default"
+	}
+	display, err = config.profile(name)
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+
+	// Give up on a source that doesn't answer, rather than hanging.
+	if s, ok := arguments["--fetch-timeout"].(string); ok {
+		upstreamHTTP.Timeout, err = time.ParseDuration(s)
+		if err != nil || upstreamHTTP.Timeout < 0 {
+			c.Printf("<error>--fetch-timeout must be a duration, such as 20s.<reset>\n")
+			os.Exit(1)
+		}
+	}
+
+	// Try again when the source is down for a moment.
+	if s, ok := arguments["--retries"].(string); ok {
+		retries, err = strconv.Atoi(s)
+		if err != nil || retries < 0 {
+			c.Printf("<error>--retries must be 0 or more.<reset>\n")
+			os.Exit(1)
+		}
+	}
+
+	// Fetch from somewhere else, such as another region, a mock upstream or a SIRI-SM service.
+	endpoint, _ := arguments["--endpoint"].(string)
+	if source, _ := arguments["--source"].(string); source == "acis" && endpoint == "" {
+		url, _ := arguments["--base-url"].(string)
+		region, _ := arguments["--region"].(string)
+		endpoint, err = config.acisURL(url, region)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+	}
+	if arguments["remote"] == true {
+		// Or from another busterm, which does the fetching for us.
+		arguments["--source"], endpoint = "remote", arguments["--server"].(string)
+	}
+	if source, ok := arguments["--source"].(string); ok {
+		key, _ := arguments["--api-key"].(string)
+		provider, err = openProvider(source, endpoint, key)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+	}
+	// Fall back on other sources when it fails.
+	provider, err = config.withFailover(provider)
+	if err != nil {
+		c.Printf("<error>%s<reset>\n", err)
+		os.Exit(1)
+	}
+	// Or don't fetch at all.
+	offline = arguments["--offline"] == true
+
+	// List the sources and what they can fill in.
+	if arguments["providers"] == true {
+		listProviders(c)
+		return
+	}
+
+	// Answer chat messages.
+	if arguments["bot"] == true {
+		var bot interface{ Run() }
+		if arguments["--irc"] == true {
+			bot, err = NewIRC(config.IRC, NewBoards(cache))
+		} else {
+			bot, err = NewMatrix(config.Matrix, NewBoards(cache))
+		}
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		bot.Run()
+		return
+	}
+
+	// Pretend to be the upstream site.
+	if arguments["mock-upstream"] == true {
+		port, ok := arguments["--port"].(string)
+		if !ok {
+			port = "7655"
+		}
+		delay, err := time.ParseDuration(arguments["--delay"].(string))
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		if err := mockUpstream(port, arguments["--scenario"].(string), delay); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run the scheduled jobs.
+	if arguments["schedule"] == true {
+		if err := schedule(config); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Benchmark fetching a list of stops.
+	if arguments["bench"] == true {
+		stops, err := readStops(arguments["--stops"].(string))
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		concurrency, err := strconv.Atoi(arguments["--concurrency"].(string))
+		if err != nil || concurrency < 1 {
+			c.Printf("<error>--concurrency must be a positive number.<reset>\n")
+			os.Exit(1)
+		}
+		rounds, err := strconv.Atoi(arguments["--rounds"].(string))
+		if err != nil || rounds < 1 {
+			c.Printf("<error>--rounds must be a positive number.<reset>\n")
+			os.Exit(1)
+		}
+		report(bench(stops, concurrency, rounds))
+		return
+	}
+
+	// Soak test the pipeline for leaks before a long deployment.
+	if arguments["soak"] == true {
+		stops, err := readStops(arguments["--stops"].(string))
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		concurrency, err := strconv.Atoi(arguments["--concurrency"].(string))
+		if err != nil || concurrency < 1 {
+			c.Printf("<error>--concurrency must be a positive number.<reset>\n")
+			os.Exit(1)
+		}
+		duration, err := time.ParseDuration(arguments["--duration"].(string))
+		if err != nil || duration <= 0 {
+			c.Printf("<error>--duration must be a positive duration, such as 24h.<reset>\n")
+			os.Exit(1)
+		}
+		// Boards are kept the way the API keeps them.
+		cache, err = openCache(config.Cache, cacheTTL)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		s := soak(NewBoards(cache), stops, concurrency, duration)
+		s.Report(duration)
+		if s.leaking(concurrency) {
+			c.Printf("<warn>goroutines grew from %d to %d, something may be leaking.<reset>\n", s.settled.goroutines, s.last.goroutines)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Print a stop's board for a noticeboard.
+	if arguments["export"] == true {
+		code := resolveAlias(arguments["<code>"].(string))
+		if err := checkCode(code); err != nil {
+			c.Printf("%s", err)
+			os.Exit(exitCode(err))
+		}
+		buses, err := getBuses(context.Background(), code)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(exitCode(err))
+		}
+		if err := exportHTML(os.Stdout, code, filter.Apply(buses), filter); err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Wait for a bus, for shell scripts.
+	if arguments["wait"] == true {
+		code := resolveAlias(arguments["<code>"].(string))
+		if err := checkCode(code); err != nil {
+			c.Printf("%s", err)
+			os.Exit(exitCode(err))
+		}
+		var timeout time.Duration
+		if s, ok := arguments["--timeout"].(string); ok {
+			timeout, err = time.ParseDuration(s)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		want := Filter{}
+		if list, ok := arguments["--service"].(string); ok {
+			want.Services = splitList(list)
+		}
+		bus, err := waitFor(code, want, arguments["--until-due"] == true, timeout, 30*time.Second)
+		if errors.Is(err, errTimeout) {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(2)
+		}
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println(bus)
+		return
+	}
+
+	// Keep favourite stops, and show them all at once.
+	if arguments["fav"] == true {
+		code, _ := arguments["<code>"].(string)
+		code = resolveAlias(code)
+		switch {
+		case arguments["add"] == true:
+			if err := checkCode(code); err != nil {
+				c.Printf("%s", err)
+				os.Exit(exitCode(err))
+			}
+			name, _ := arguments["<name>"].(string)
+			err = addFavourite(code, name)
+		case arguments["rm"] == true:
+			err = removeFavourite(code)
+		case arguments["list"] == true:
+			err = listFavourites(c)
+		default:
+			err = showFavourites(c)
+		}
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Without a stop, show the default one or pick one.
+	if arguments["-n"] != true && arguments["--naptan"] != true && arguments["--simulate"] == nil && arguments["-a"] != true && arguments["--api"] != true {
+		code, ok := config.defaultStop()
+		if !ok {
+			code, err = pickStop()
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		arguments["<code>"] = code
+		arguments["-n"] = true
+	}
+
+	// Check NapTAN option. Several stops can be given, comma separated.
+	if arguments["-n"] == true || arguments["--naptan"] == true {
+		refs := splitList(arguments["<code>"].(string))
+		for i, code := range refs {
+			code = resolveAlias(code)
+			refs[i] = code
+			if err := checkCode(code); err != nil {
+				c.Printf("%s", err)
+				os.Exit(exitCode(err))
+			}
+			rememberStop(code)
+		}
+		if len(refs) == 0 {
+			c.Printf("%s\n", errNoStop)
+			os.Exit(1)
+		}
+		// Keep the watched stops across restarts. Offline, or failing over to saved boards,
+		// it's where boards come from.
+		if arguments["-t"] == true || offline || config.fallsBackToSaved() {
+			if err := config.openWarm(refs...); err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		// A bolt or redis cache may have a newer board.
+		if offline {
+			cache, err = openCache(config.Cache, cacheTTL)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		if arguments["-t"] == true {
+			every := 30 * time.Second
+			if s, ok := arguments["<interval>"].(string); ok {
+				every, err = parseInterval(s)
+				if err != nil {
+					c.Printf("<error>%s<reset>\n", err)
+					os.Exit(1)
+				}
+			}
+			history, _ := arguments["--record"].(string)
+			watch(refs, every, history)
+		}
+		// Get Buses, for every stop at once. The exit status is the first failure's.
+		status := 0
+		for i, b := range fetchAll(context.Background(), refs) {
+			if i > 0 {
+				fmt.Println()
+			}
+			if b.err != nil {
+				if len(refs) > 1 {
+					c.Printf("Stop Ref: <headline>%s<reset>\n", b.ref)
+				}
+				c.Printf("<error>%s<reset>\n", b.err)
+				if status == 0 {
+					status = exitCode(b.err)
+				}
+				continue
+			}
+			PrintTable(filter.Apply(b.buses), b.ref)
+		}
+		// Save the boards for the failover to fall back on next time.
+		if warm != nil && !offline {
+			if err := warm.Save(); err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		if status != 0 {
+			os.Exit(status)
+		}
+	}
+
+	// Replay a recorded history.
+	if path, ok := arguments["--simulate"].(string); ok {
+		history, err := loadHistory(path)
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		speed, err := parseSpeed(arguments["--speed"].(string))
+		if err != nil {
+			c.Printf("<error>%s<reset>\n", err)
+			os.Exit(1)
+		}
+		replay = NewSimulation(history, speed)
+		clock = replay.Now
+		// Without --api, replay through the watch mode, refreshing as often as it would.
+		if arguments["-a"] != true && arguments["--api"] != true {
+			watch([]string{replay.Ref()}, time.Duration(float64(30*time.Second)/speed), "")
+		}
+	}
+
+	// Serve the API.
+	if arguments["-a"] == true || arguments["--api"] == true {
+		if arguments["--public"] == true {
+			goPublic()
+		}
+		port := "7654"
+		if p, ok := arguments["--port"].(string); ok {
+			port = p
+		}
+		// Open the cache backend, checking the rest of the deployment first with --preflight.
+		if arguments["--preflight"] == true {
+			preflight(c, config.Cache, arguments["--lenient"] == true)
+		} else {
+			cache, err = openCache(config.Cache, cacheTTL)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		server := NewServer(NewBoards(cache), port)
+		if arguments["--public"] == true {
+			server.GoPublic()
+		}
+		// Keep an audit log of every request.
+		if config.API.AuditLog != "" {
+			server.Auditor, err = OpenAuditor(config.API.AuditLog)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		// Require API keys when the config file has some.
+		if len(config.API.Keys) > 0 {
+			server.Quotas = NewQuotas(config.API.Keys)
+		}
+		// Only let in the addresses the config file allows.
+		if len(config.API.Allow) > 0 || len(config.API.Deny) > 0 {
+			server.Access, err = NewACL(config.API.Allow, config.API.Deny)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		// Keep the configured stops across restarts, fetching them straight away.
+		if replay == nil {
+			if err := config.openWarm(); err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+		}
+		if replay == nil && !offline {
+			for _, ref := range config.Stops {
+				go server.lookup(context.Background(), ref)
+			}
+			// Poll hot stops on their own intervals.
+			every, err := parsePolls(config.API.Poll)
+			if err != nil {
+				c.Printf("<error>%s<reset>\n", err)
+				os.Exit(1)
+			}
+			server.Polls.Set(every)
+		}
+		// Take some changes to the config file without a restart.
+		server.ReloadOnHangup(path)
+		server.ListenAndServe()
+	}
+}
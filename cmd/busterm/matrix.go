@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// MatrixConfig holds the account the Matrix bot runs as.
+type MatrixConfig struct {
+	// Homeserver is the base URL of the bot's homeserver. (https://matrix.example.org)
+	Homeserver string `toml:"homeserver"`
+	// AccessToken is the bot account's access token. Prefer "${MATRIX_TOKEN}" or "file:".
+	AccessToken string `toml:"access_token"`
+}
+
+// botRetry is how long the bots wait before trying again after losing their connection.
+var botRetry = 30 * time.Second
+
+// Matrix is a bot that answers `!bus <code|alias>` in the rooms it's in with the stop's board.
+// It joins rooms it's invited to.
+type Matrix struct {
+	config MatrixConfig
+	boards *Boards
+	client *http.Client
+	user   string
+	txn    int
+}
+
+// NewMatrix sets up the bot to answer with boards, checking the access token with the homeserver.
+func NewMatrix(config MatrixConfig, boards *Boards) (*Matrix, error) {
+	if config.Homeserver == "" || config.AccessToken == "" {
+		return nil, errors.New("the Matrix bot needs homeserver and access_token in the [matrix] table of the config file.")
+	}
+	config.Homeserver = strings.TrimSuffix(config.Homeserver, "/")
+	// Syncs are held open for 30 seconds.
+	m := &Matrix{config: config, boards: boards, client: &http.Client{Timeout: time.Minute}}
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := m.call("GET", "/account/whoami", nil, &whoami); err != nil {
+		return nil, err
+	}
+	m.user = whoami.UserID
+	return m, nil
+}
+
+// matrixSync is the part of a sync response the bot uses.
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Sender  string `json:"sender"`
+					Content struct {
+						MsgType string `json:"msgtype"`
+						Body    string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+// Run answers messages until busterm is stopped. Messages sent while it wasn't running are skipped.
+func (m *Matrix) Run() {
+	since := ""
+	for {
+		var sync matrixSync
+		path := "/sync?timeout=30000"
+		if since == "" {
+			// Only start from now, not the rooms' history.
+			path = "/sync?timeout=0&filter=" + url.QueryEscape(`{"room":{"timeline":{"limit":1}}}`)
+		} else {
+			path += "&since=" + url.QueryEscape(since)
+		}
+		if err := m.call("GET", path, nil, &sync); err != nil {
+			log.Println("matrix:", err)
+			time.Sleep(botRetry)
+			continue
+		}
+		first := since == ""
+		since = sync.NextBatch
+
+		for room := range sync.Rooms.Invite {
+			if err := m.call("POST", "/join/"+url.PathEscape(room), struct{}{}, nil); err != nil {
+				log.Println("matrix: joining", room+":", err)
+			}
+		}
+		if first {
+			continue
+		}
+		for room, joined := range sync.Rooms.Join {
+			for _, event := range joined.Timeline.Events {
+				if event.Type != "m.room.message" || event.Sender == m.user || event.Content.MsgType != "m.text" {
+					continue
+				}
+				if reply, ok := botCommand(m.boards, event.Content.Body, boardReply); ok {
+					if err := m.send(room, reply); err != nil {
+						log.Println("matrix: replying in", room+":", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// send posts a board to a room as a notice, in a code block so the columns line up.
+func (m *Matrix) send(room string, text string) error {
+	m.txn++
+	message := map[string]string{
+		"msgtype":        "m.notice",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": "<pre><code>" + html.EscapeString(text) + "</code></pre>",
+	}
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/busterm-%d-%d", url.PathEscape(room), time.Now().UnixNano(), m.txn)
+	return m.call("PUT", path, message, nil)
+}
+
+// call makes a request to the client-server API, decoding the answer into out when it's given.
+func (m *Matrix) call(method string, path string, body any, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, m.config.Homeserver+"/_matrix/client/v3"+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.config.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		var matrixErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&matrixErr)
+		if matrixErr.Error == "" {
+			matrixErr.Error = res.Status
+		}
+		return errors.New("the homeserver says " + matrixErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// botCommand answers a chat message if it's a `!bus <code|alias>` command, with answer's take on
+// the stop's board from boards.
+func botCommand(boards *Boards, message string, answer func(code string, buses []Bus) string) (string, bool) {
+	fields := strings.Fields(message)
+	if len(fields) == 0 || fields[0] != "!bus" {
+		return "", false
+	}
+	if len(fields) != 2 {
+		return "Usage: !bus <code|alias>", true
+	}
+	code := resolveAlias(fields[1])
+	if err := checkCode(code); err != nil {
+		return fields[1] + " isn't a stop I know.", true
+	}
+	buses, err := boards.lookup(context.Background(), code)
+	if err != nil {
+		return "Bus times for " + code + " aren't available right now.", true
+	}
+	return answer(code, buses), true
+}
+
+// boardReply answers with the stop's board.
+func boardReply(code string, buses []Bus) string {
+	return strings.TrimRight(boardText(clif.NewMonochromeOutput, filter, buses, code), "\n")
+}
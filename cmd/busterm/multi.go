@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// board is the buses at a stop, or why they couldn't be fetched.
+type board struct {
+	ref   string
+	buses []Bus
+	err   error
+}
+
+// fetchAll fetches several stops at once, such as both sides of a road, and returns their
+// boards in the same order.
+func fetchAll(ctx context.Context, refs []string) []board {
+	boards := make([]board, len(refs))
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buses, err := getBuses(ctx, ref)
+			boards[i] = board{ref, buses, err}
+		}()
+	}
+	wg.Wait()
+	return boards
+}
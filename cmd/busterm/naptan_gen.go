@@ -0,0 +1,110 @@
+//go:build ignore
+
+// naptan_gen downloads the national NaPTAN stops CSV and keeps the columns busterm uses,
+// compressed, as assets/naptan.csv.gz for embedding. Run it with `go generate`, which keeps
+// Yorkshire's stops, or as `go run naptan_gen.go -o ~/.config/busterm/assets/naptan.csv.gz`
+// for the whole country without rebuilding.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// source is the NaPTAN download of every stop in Great Britain.
+const source = "https://naptan.api.dft.gov.uk/v1/access-nodes?dataFormat=csv"
+
+// keep are the columns kept, in order.
+var keep = []string{"ATCOCode", "NaptanCode", "CommonName", "Indicator", "Street", "Bearing", "LocalityName", "Longitude", "Latitude", "Status"}
+
+func main() {
+	output := flag.String("o", "assets/naptan.csv.gz", "file to write")
+	areas := flag.String("areas", "", "ATCO area codes to keep, comma separated (450 is West Yorkshire), else every stop")
+	flag.Parse()
+
+	res, err := http.Get(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		log.Fatal("NaPTAN download: ", res.Status)
+	}
+
+	out, err := os.Create(*output + ".tmp")
+	if err != nil {
+		log.Fatal(err)
+	}
+	gz, _ := gzip.NewWriterLevel(out, gzip.BestCompression)
+	w := csv.NewWriter(gz)
+
+	r := csv.NewReader(res.Body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		log.Fatal(err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range keep {
+		if _, ok := columns[name]; !ok {
+			log.Fatal("NaPTAN download has no ", name, " column")
+		}
+	}
+
+	w.Write(keep)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Leave out stops that have been taken away.
+		if record[columns["Status"]] != "active" {
+			continue
+		}
+		// Leave out stops outside the areas, by the start of their ATCO codes.
+		if *areas != "" && !inAreas(record[columns["ATCOCode"]], strings.Split(*areas, ",")) {
+			continue
+		}
+		row := []string{}
+		for _, name := range keep {
+			row = append(row, record[columns[name]])
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(*output+".tmp", *output); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inAreas reports if an ATCO code is in one of the areas, which its first three digits name.
+func inAreas(atco string, areas []string) bool {
+	for _, area := range areas {
+		if strings.HasPrefix(atco, strings.TrimSpace(area)) {
+			return true
+		}
+	}
+	return false
+}
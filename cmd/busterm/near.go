@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/return/busterm/pkg/render"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// postcodesAPI geocodes UK postcodes.
+var postcodesAPI = "https://api.postcodes.io"
+
+// geocode finds where a postcode such as "LS1 4AP" is.
+func geocode(postcode string) (float64, float64, error) {
+	postcode = strings.ToUpper(strings.Join(strings.Fields(postcode), " "))
+	res, err := upstreamClient().Get(postcodesAPI + "/postcodes/" + url.PathEscape(postcode))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == 404:
+		return 0, 0, errors.New("unknown postcode: " + postcode)
+	case res.StatusCode != 200:
+		return 0, 0, errors.New("postcodes.io says " + res.Status)
+	}
+
+	var body struct {
+		Result struct {
+			Latitude  *float64 `json:"latitude"`
+			Longitude *float64 `json:"longitude"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return 0, 0, err
+	}
+	// Some postcodes, such as PO boxes, have no location.
+	if body.Result.Latitude == nil || body.Result.Longitude == nil {
+		return 0, 0, errors.New("postcode " + postcode + " has no location.")
+	}
+	return *body.Result.Latitude, *body.Result.Longitude, nil
+}
+
+// nearby is a stop and how far away it is in metres.
+type nearby struct {
+	stop     Stop
+	distance float64
+}
+
+// nearestStops finds the NaPTAN stops closest to a place, nearest first.
+func nearestStops(lat, lon float64, limit int) ([]nearby, error) {
+	found := []nearby{}
+	err := eachStop(func(s Stop) bool {
+		if s.Latitude == 0 && s.Longitude == 0 {
+			return true
+		}
+		found = append(found, nearby{s, distance(lat, lon, s.Latitude, s.Longitude)})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(a, b int) bool { return found[a].distance < found[b].distance })
+	return found[:min(limit, len(found))], nil
+}
+
+// distance is how many metres apart two places are, as the crow flies.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earth = 6371000
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earth * math.Asin(math.Sqrt(a))
+}
+
+// printNear prints the stops nearest a postcode, with the codes to use with -n.
+func printNear(c clif.Output, postcode string, limit int) error {
+	lat, lon, err := geocode(postcode)
+	if err != nil {
+		return err
+	}
+	stops, err := nearestStops(lat, lon, limit)
+	if err != nil {
+		return err
+	}
+	if len(stops) == 0 {
+		if err := checkStops(); err != nil {
+			return err
+		}
+		return errors.New("no stops near " + postcode + ".")
+	}
+	rows := [][]string{}
+	for _, n := range stops {
+		code := n.stop.Naptan
+		if code == "" {
+			code = n.stop.ATCO
+		}
+		rows = append(rows, []string{code, "<headline>" + n.stop.Title() + "<reset>", n.stop.Street, fmt.Sprintf("%.0f m", n.distance), n.stop.Bearing})
+	}
+	c.Printf("%s\n", render.Columns([]string{"Code", "Stop", "Street", "Distance", "Bearing"}, rows, 0))
+	return nil
+}
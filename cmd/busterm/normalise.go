@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+var (
+	// destinations expands the shorthand used by the departure boards.
+	// Keys are upper case and may span several words. Extend it with [destinations] in the config file.
+	destinations = map[string]string{
+		"CTR":  "Centre",
+		"CNTR": "Centre",
+		"CEN":  "Centre",
+		"STN":  "Station",
+		"INT":  "Interchange",
+		"HOSP": "Hospital",
+		"INF":  "Infirmary",
+		"UNI":  "University",
+		"SQ":   "Square",
+		"RD":   "Road",
+		"AVE":  "Avenue",
+		"PK":   "Park",
+		"LS":   "Leeds",
+		"BD":   "Bradford",
+		"HD":   "Huddersfield",
+		"HX":   "Halifax",
+		"WF":   "Wakefield",
+	}
+
+	// services renames services for display. Several services can share a name to merge them.
+	services = map[string]string{}
+
+	// words kept in lower case unless they start the destination.
+	smallWords = map[string]bool{"and": true, "of": true, "the": true, "upon": true, "on": true, "in": true, "via": true}
+)
+
+// addDestinations merges user substitutions into the destination map.
+func addDestinations(subs map[string]string) {
+	for from, to := range subs {
+		destinations[strings.ToUpper(strings.TrimSpace(from))] = to
+	}
+}
+
+// addServices merges user service renames. A key may list several services. ("PR1/PR2" = "Park & Ride")
+func addServices(renames map[string]string) {
+	for from, to := range renames {
+		for _, service := range strings.FieldsFunc(from, func(r rune) bool { return r == '/' || r == ',' }) {
+			services[strings.ToUpper(strings.TrimSpace(service))] = to
+		}
+	}
+}
+
+// rename returns the display name of a service.
+func rename(service string) string {
+	if to, ok := services[strings.ToUpper(strings.TrimSpace(service))]; ok {
+		return to
+	}
+	return service
+}
+
+// normalise rewrites a destination such as "LS CITY CTR" into "Leeds City Centre".
+func normalise(dest string) string {
+	words := strings.Fields(dest)
+	out := []string{}
+
+	for i := 0; i < len(words); {
+		// Prefer the longest run of words that has a substitution.
+		matched := 0
+		for n := len(words) - i; n > 0; n-- {
+			key := strings.ToUpper(strings.Join(words[i:i+n], " "))
+			if sub, ok := destinations[key]; ok {
+				out = append(out, sub)
+				matched = n
+				break
+			}
+		}
+		if matched > 0 {
+			i += matched
+			continue
+		}
+
+		out = append(out, titleCase(words[i], len(out) == 0))
+		i++
+	}
+	return strings.Join(out, " ")
+}
+
+// titleCase turns a shouted word ("CITY") into "City", leaving mixed case and codes alone.
+func titleCase(word string, first bool) string {
+	hasLower, hasDigit := false, false
+	for _, r := range word {
+		hasLower = hasLower || unicode.IsLower(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	// Already mixed case, or a code such as "X84".
+	if hasLower || hasDigit {
+		return word
+	}
+
+	lower := strings.ToLower(word)
+	if !first && smallWords[lower] {
+		return lower
+	}
+
+	// Capitalise each part of hyphenated names. (Stockton-on-Tees)
+	parts := strings.Split(lower, "-")
+	for i, part := range parts {
+		if i > 0 && smallWords[part] {
+			continue
+		}
+		runes := []rune(part)
+		if len(runes) > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		parts[i] = string(runes)
+	}
+	return strings.Join(parts, "-")
+}
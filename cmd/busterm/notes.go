@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Limits on notes added through the API.
+const (
+	// noteLength is the longest a note may be, in characters.
+	noteLength = 140
+	// notesPerStop is how many added notes a stop keeps. Older ones make way for new ones.
+	notesPerStop = 10
+)
+
+// Notes are local notes about stops, such as "shelter broken" or "use stand B after 18:00",
+// shown under the board's heading. Some are written in the config file, others are added
+// through the API and kept in a file.
+type Notes struct {
+	mu    sync.Mutex
+	path  string
+	fixed map[string][]string
+	added map[string][]string
+}
+
+// notes are the notes about stops.
+var notes = &Notes{fixed: map[string][]string{}, added: map[string][]string{}}
+
+// notesPath returns the default location of the file notes added through the API are kept in.
+func notesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "busterm", "notes.json")
+}
+
+// LoadNotes loads the notes added through the API from a file, alongside the config file's own.
+// A missing file is fine.
+func LoadNotes(path string, fixed map[string][]string) (*Notes, error) {
+	n := &Notes{path: path, fixed: fixed, added: map[string][]string{}}
+	if fixed == nil {
+		n.fixed = map[string][]string{}
+	}
+	if path == "" {
+		return n, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &n.added); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return n, nil
+}
+
+// For returns the notes about a stop, the config file's first.
+func (n *Notes) For(ref string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append(append([]string{}, n.fixed[ref]...), n.added[ref]...)
+}
+
+// SetFixed replaces the config file's notes, when it's reloaded.
+func (n *Notes) SetFixed(fixed map[string][]string) {
+	if fixed == nil {
+		fixed = map[string][]string{}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.fixed = fixed
+}
+
+// Add adds a note about a stop and saves it.
+func (n *Notes) Add(ref string, note string) error {
+	note = strings.Join(strings.Fields(note), " ")
+	if note == "" || utf8.RuneCountInString(note) > noteLength {
+		return errBadNote
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	added := append(n.added[ref], note)
+	if len(added) > notesPerStop {
+		added = added[len(added)-notesPerStop:]
+	}
+	n.added[ref] = added
+	return n.save()
+}
+
+// errBadNote is returned for notes that are empty or too long.
+var errBadNote = errors.New("a note must be 1 to 140 characters.")
+
+// save writes the added notes to their file.
+func (n *Notes) save() error {
+	if n.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.added, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(n.path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash can't leave half a file behind.
+	tmp := n.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, n.path)
+}
+
+// StopInfo is what the API knows about a stop besides its departures.
+type StopInfo struct {
+	Naptan string   `json:"naptan"`
+	Name   string   `json:"name,omitempty"`
+	Notes  []string `json:"notes"`
+}
+
+// serveStop sends a stop's name and notes.
+func serveStop(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	code = resolveAlias(code)
+	if err := checkCode(code); err != nil {
+		w.WriteHeader(400)
+		fmt.Fprint(w, invalidNaptan)
+		return
+	}
+	data, _ := json.Marshal(StopInfo{Naptan: code, Name: stopName(code), Notes: notes.For(code)})
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// serveAddNote adds the note in the request body to a stop. Public instances don't take notes,
+// as anyone could write anything on everyone's boards.
+func (s *Server) serveAddNote(w http.ResponseWriter, r *http.Request, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.Public {
+		w.WriteHeader(403)
+		fmt.Fprint(w, forbidden)
+		return
+	}
+	code = resolveAlias(code)
+	if err := checkCode(code); err != nil {
+		w.WriteHeader(400)
+		fmt.Fprint(w, invalidNaptan)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4*noteLength+1))
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprint(w, badNote)
+		return
+	}
+	if err := notes.Add(code, string(body)); err != nil {
+		if errors.Is(err, errBadNote) {
+			w.WriteHeader(400)
+			fmt.Fprint(w, badNote)
+			return
+		}
+		log.Println("notes:", err)
+		w.WriteHeader(500)
+		fmt.Fprint(w, unable)
+		return
+	}
+	data, _ := json.Marshal(StopInfo{Naptan: code, Name: stopName(code), Notes: notes.For(code)})
+	w.WriteHeader(201)
+	w.Write(data)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// pageSize is how many departures fit on a page: the profile's rows, or else as many as fit
+// in the terminal. It's 0 when neither is known.
+func pageSize(p Profile) int {
+	if p.Rows > 0 {
+		return p.Rows
+	}
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		if _, height, err = term.GetSize(int(os.Stdin.Fd())); err != nil {
+			return 0
+		}
+	}
+
+	// Leave room for the heading, legend, table header, footer and page indicator.
+	size := height - 13
+	switch {
+	case p.Layout == "large":
+		// Each bus takes the block font, its destination and a blank line.
+		size = (height - 1) / (len(font['0']) + 2)
+	case p.Compact:
+		size = height - 8
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// paginate splits a board into pages of size departures.
+func paginate(bus []Bus, size int) [][]Bus {
+	pages := [][]Bus{}
+	for len(bus) > size {
+		pages = append(pages, bus[:size])
+		bus = bus[size:]
+	}
+	return append(pages, bus)
+}
+
+// showPages shows the board until the next refresh. When it doesn't fit and the profile cycles,
+// the pages take turns with a page indicator, like the displays at bus stations.
+func showPages(c clif.Output, bus []Bus, ref string, every time.Duration) {
+	size := pageSize(display)
+	if display.Cycle <= 0 || size == 0 || len(bus) <= size {
+		c.Printf("\033[1;1H")
+		PrintTable(bus, ref)
+		fmt.Printf("\r           \r")
+		time.Sleep(every)
+		return
+	}
+
+	pages := paginate(bus, size)
+	cycle := time.Duration(display.Cycle) * time.Second
+	for shown, i := time.Duration(0), 0; shown < every; shown, i = shown+cycle, (i+1)%len(pages) {
+		c.Printf("\033[1;1H")
+		PrintTable(pages[i], ref)
+		// Clear whatever a longer page left below.
+		fmt.Printf("\rPage %d/%d of %d departures\033[J", i+1, len(pages), len(bus))
+		time.Sleep(min(cycle, every-shown))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// recentStops is how many recently used stops are remembered for the picker.
+const recentStops = 10
+
+// recentPath returns the location of the list of recently used stops.
+func recentPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "busterm", "recent.json")
+}
+
+// recent returns the recently used stops, most recent first.
+func recent() []string {
+	codes := []string{}
+	data, err := os.ReadFile(recentPath())
+	if err == nil {
+		json.Unmarshal(data, &codes)
+	}
+	return codes
+}
+
+// rememberStop puts a stop at the top of the recently used stops. It's best effort:
+// a read-only home directory shouldn't stop anyone seeing their buses.
+func rememberStop(code string) {
+	path := recentPath()
+	if path == "" {
+		return
+	}
+	codes := []string{code}
+	for _, c := range recent() {
+		if c != code && len(codes) < recentStops {
+			codes = append(codes, c)
+		}
+	}
+	data, _ := json.Marshal(codes)
+	if os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// errNoStop is returned when there's no stop to show and no terminal to pick one in.
+var errNoStop = errors.New("no stop given, use -n <code> (find codes with busterm search or busterm near).")
+
+// errPickCancelled is returned when the picker is left without picking a stop.
+var errPickCancelled = errors.New("no stop picked.")
+
+// pickStop lets the user find a stop by typing its name, starting with their favourites and the
+// stops they've used recently, and returns its code.
+func pickStop() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errNoStop
+	}
+	// Without stops to search, only favourites and recent stops can be picked.
+	if err := checkStops(); err != nil && len(pickChoices("")) == 0 {
+		return "", err
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+	// Read the stops list while the first letters are typed.
+	go searchIndex()
+
+	query := ""
+	selected := 0
+	choices := pickChoices(query)
+	buf := make([]byte, 16)
+	for {
+		drawPicker(query, choices, selected)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		key := string(buf[:n])
+		switch {
+		case key == "\r" || key == "\n":
+			if selected < len(choices) {
+				clearPicker()
+				return choices[selected].code, nil
+			}
+		case key == "\x03" || key == "\x1b":
+			// Ctrl-C or Esc.
+			clearPicker()
+			return "", errPickCancelled
+		case key == "\x1b[A" || key == "\x10":
+			// Up or Ctrl-P.
+			if selected > 0 {
+				selected--
+			}
+		case key == "\x1b[B" || key == "\x0e":
+			// Down or Ctrl-N.
+			if selected < len(choices)-1 {
+				selected++
+			}
+		case key == "\x7f" || key == "\b":
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				choices, selected = pickChoices(query), 0
+			}
+		case key == "\x15":
+			// Ctrl-U clears the query.
+			query = ""
+			choices, selected = pickChoices(query), 0
+		default:
+			typed := strings.Map(func(r rune) rune {
+				if unicode.IsPrint(r) {
+					return r
+				}
+				return -1
+			}, key)
+			if typed != "" && !strings.HasPrefix(key, "\x1b") {
+				query += typed
+				choices, selected = pickChoices(query), 0
+			}
+		}
+	}
+}
+
+// choice is a stop offered by the picker.
+type choice struct {
+	code  string
+	label string
+}
+
+// pickChoices lists the stops matching the query, or the favourite and recently used stops when there's none.
+func pickChoices(query string) []choice {
+	choices := []choice{}
+	if strings.TrimSpace(query) == "" {
+		favourites, _ := loadFavourites()
+		for _, f := range favourites {
+			label := f.Name
+			if name := stopName(f.Code); name != "" {
+				label = strings.TrimSpace(f.Name + " " + name)
+			}
+			choices = append(choices, choice{f.Code, label + " (favourite)"})
+		}
+		for _, code := range recent() {
+			label := "(recent)"
+			if name := stopName(code); name != "" {
+				label = name + " (recent)"
+			}
+			choices = append(choices, choice{code, label})
+		}
+		return choices
+	}
+	stops, _ := searchStops(query, 10)
+	for _, s := range stops {
+		code := s.Naptan
+		if code == "" {
+			code = s.ATCO
+		}
+		choices = append(choices, choice{code, s.Title() + ", " + s.Locality})
+	}
+	return choices
+}
+
+// drawPicker draws the query with the choices below it, over the last drawing, leaving the cursor
+// after the query.
+func drawPicker(query string, choices []choice, selected int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r\033[JStop: %s", query)
+	for i, c := range choices {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "\r\n%s%-9s %s", marker, c.code, c.label)
+	}
+	lines := len(choices)
+	if lines == 0 && query != "" {
+		b.WriteString("\r\n  no stops match")
+		lines = 1
+	}
+	if lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", lines)
+	}
+	fmt.Fprintf(&b, "\r\033[%dC", len("Stop: ")+utf8.RuneCountInString(query))
+	os.Stdout.WriteString(b.String())
+}
+
+// clearPicker removes the picker from the screen.
+func clearPicker() {
+	os.Stdout.WriteString("\r\033[J")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Poller keeps hot stops fresh in the API's cache, fetching each on its own interval, so a busy
+// interchange can be fetched every 15 seconds and a quiet rural stop every 2 minutes.
+type Poller struct {
+	mu    sync.Mutex
+	cache Cache
+	every map[string]time.Duration
+	// stop ends the polling of each stop.
+	stop map[string]chan struct{}
+}
+
+// NewPoller makes a poller keeping stops fresh in a cache, polling none until Set.
+func NewPoller(cache Cache) *Poller {
+	return &Poller{cache: cache, every: map[string]time.Duration{}, stop: map[string]chan struct{}{}}
+}
+
+// parsePolls reads the polling intervals from the config file, by stop code or alias.
+// ("45010687" = "15s", "village" = "2m")
+func parsePolls(intervals map[string]string) (map[string]time.Duration, error) {
+	every := map[string]time.Duration{}
+	for name, interval := range intervals {
+		ref := resolveAlias(name)
+		if err := checkCode(ref); err != nil {
+			return nil, errors.New("api.poll: " + name + " isn't a stop code.")
+		}
+		d, err := parseInterval(interval)
+		if err != nil {
+			return nil, errors.New("api.poll: " + name + ": " + err.Error())
+		}
+		every[ref] = d
+	}
+	return every, nil
+}
+
+// Set polls the stops on their intervals, starting straight away. Stops that are no longer listed
+// stop being polled and stops whose interval changed start again.
+func (p *Poller) Set(every map[string]time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ref, stop := range p.stop {
+		if d, ok := every[ref]; !ok || d != p.every[ref] {
+			close(stop)
+			delete(p.stop, ref)
+		}
+	}
+	p.every = every
+	for ref, d := range every {
+		if _, ok := p.stop[ref]; !ok {
+			p.stop[ref] = make(chan struct{})
+			go p.poll(ref, d, p.stop[ref])
+		}
+	}
+}
+
+// Every returns how often a stop is polled, if it is.
+func (p *Poller) Every(ref string) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.every[ref]
+	return d, ok
+}
+
+// poll fetches a stop into the cache every so often until it's stopped.
+func (p *Poller) poll(ref string, every time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		buses, err := getBuses(context.Background(), ref)
+		if err != nil {
+			log.Println("poll:", ref, err)
+		} else if !isStale(buses) {
+			p.cache.Put(ref, buses)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
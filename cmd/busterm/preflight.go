@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// preflightCheck is one of the checks run when the API starts with --preflight, so a broken
+// deployment is caught at boot rather than by the first person to ask for a board.
+type preflightCheck struct {
+	// name is what's checked, shown with the result. ("upstream")
+	name string
+	// run does the check, returning what's wrong.
+	run func() error
+	// degrade carries on without what failed under --lenient, saying how.
+	degrade func() string
+}
+
+// preflight checks the upstream site answers, opens the cache backend and checks the NaPTAN stops
+// list was built in. The first failure stops busterm with its exit status, unless lenient, when
+// busterm warns and carries on without it.
+func preflight(c clif.Output, caching CacheConfig, lenient bool) {
+	checks := []preflightCheck{
+		{
+			name: "upstream",
+			run: func() error {
+				if replay != nil || offline {
+					return errors.ErrUnsupported
+				}
+				if p, ok := provider.(preconnecter); ok {
+					return p.Preconnect()
+				}
+				return errors.ErrUnsupported
+			},
+			degrade: func() string {
+				return "Boards will fail until it answers."
+			},
+		},
+		{
+			name: "cache",
+			run: func() error {
+				var err error
+				cache, err = openCache(caching, cacheTTL)
+				return err
+			},
+			degrade: func() string {
+				cache = NewMemoryCache(cacheTTL)
+				return "Keeping boards in memory instead."
+			},
+		},
+		{
+			name: "naptan",
+			run:  checkStops,
+			degrade: func() string {
+				return "Stops won't have names, and search and near won't find any."
+			},
+		},
+	}
+
+	for _, check := range checks {
+		err := check.run()
+		switch {
+		case err == nil:
+			c.Printf("<success>preflight: %s ok<reset>\n", check.name)
+		case errors.Is(err, errors.ErrUnsupported):
+			// Offline, replaying, or a source busterm can't reach ahead of a fetch.
+			c.Printf("preflight: %s skipped\n", check.name)
+		case lenient:
+			c.Printf("<warn>preflight: %s: %s<reset>\n", check.name, err)
+			c.Printf("<warn>preflight: %s<reset>\n", check.degrade())
+		default:
+			c.Printf("<error>preflight: %s: %s<reset>\n", check.name, err)
+			os.Exit(exitCode(err))
+		}
+	}
+}
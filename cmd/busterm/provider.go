@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider is a source of departures. ACIS is the default; others (SIRI, GTFS-RT, TransportAPI)
+// plug in here without the CLI or API knowing the difference.
+type Provider interface {
+	// FetchDepartures returns the upcoming departures from a stop, as the source names them.
+	FetchDepartures(ctx context.Context, stopRef string) ([]Bus, error)
+}
+
+// preconnecter is a provider that can open its connection ahead of the first fetch. It returns
+// why it couldn't, so the preflight checks can tell an unreachable source at startup.
+type preconnecter interface {
+	Preconnect() error
+}
+
+// stopChecker is a provider whose stops aren't NapTAN codes, with its own check for them.
+type stopChecker interface {
+	CheckStop(ref string) error
+}
+
+// provider is where busterm fetches departures from.
+var provider Provider = withLondon(ACIS{BaseURL: baseurl, HTTPClient: upstreamClient()})
+
+// sources are the providers selectable with --source.
+var sources = []string{"acis", "siri", "gtfs-rt", "bods", "tfl", "remote"}
+
+// openProvider sets up the provider for --source. The endpoint is its URL, which ACIS doesn't need.
+// ACIS sends London stops to TfL, as it doesn't cover them.
+// The API key is for BODS or a remote busterm, which otherwise take the one from `busterm auth set`.
+func openProvider(source string, endpoint string, key string) (Provider, error) {
+	switch source {
+	case "acis":
+		if endpoint == "" {
+			endpoint = baseurl
+		}
+		return withLondon(ACIS{BaseURL: endpoint, HTTPClient: upstreamClient()}), nil
+	case "siri":
+		if endpoint == "" {
+			return nil, errors.New("the siri source needs an --endpoint.")
+		}
+		// Services that don't need a requestor ref get our name.
+		requestor, err := credential("siri")
+		if err != nil {
+			requestor = "busterm"
+		}
+		return SIRI{Endpoint: endpoint, RequestorRef: requestor, HTTPClient: upstreamClient()}, nil
+	case "gtfs-rt":
+		if endpoint == "" {
+			return nil, errors.New("the gtfs-rt source needs an --endpoint, the URL or file of a TripUpdates feed.")
+		}
+		return GTFSRT{Feed: endpoint, HTTPClient: upstreamClient()}, nil
+	case "bods":
+		if endpoint == "" {
+			endpoint = bodsFeed
+		}
+		if key == "" {
+			var err error
+			if key, err = credential("bods"); err != nil {
+				return nil, err
+			}
+		}
+		return BODS{Feed: endpoint, APIKey: key, HTTPClient: upstreamClient()}, nil
+	case "tfl":
+		return &TfL{HTTPClient: upstreamClient()}, nil
+	case "remote":
+		if endpoint == "" {
+			return nil, errors.New("the remote source needs the URL of a busterm API, such as --server https://bus.example.com.")
+		}
+		// Most instances don't take keys.
+		if key == "" {
+			key, _ = credential("remote")
+		}
+		return Remote{Server: endpoint, APIKey: key, HTTPClient: upstreamClient()}, nil
+	}
+	return nil, errors.New("unknown source: " + source + " (expected " + strings.Join(sources, ", ") + ")")
+}
+
+// departure is a bus and when it leaves, for providers that get exact times.
+type departure struct {
+	bus Bus
+	at  time.Time
+}
+
+// soonestFirst sorts departures by when they leave and returns their buses.
+func soonestFirst(departures []departure) []Bus {
+	sort.SliceStable(departures, func(a, b int) bool { return departures[a].at.Before(departures[b].at) })
+	buses := []Bus{}
+	for _, d := range departures {
+		buses = append(buses, d.bus)
+	}
+	return buses
+}
+
+// sortBuses puts a board in a stable order: soonest first, then by service and destination, so
+// every source's boards come out the same way on every refresh. Buses without a readable time go last.
+func sortBuses(buses []Bus) {
+	slices.SortStableFunc(buses, func(x, y Bus) int {
+		switch {
+		case x.Expected.IsZero() != y.Expected.IsZero():
+			if x.Expected.IsZero() {
+				return 1
+			}
+			return -1
+		case !x.Expected.Equal(y.Expected):
+			return x.Expected.Compare(y.Expected)
+		case x.Service != y.Service:
+			if serviceLess(x.Service, y.Service) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(x.To, y.To)
+	})
+}
+
+// serviceLess orders services by their number, with lettered variants after it, then services
+// that don't start with a number by name. ("1", "33", "36", "36A", "37", "PR1", "X84")
+func serviceLess(a, b string) bool {
+	na, resta, erra := leadingNumber(a)
+	nb, restb, errb := leadingNumber(b)
+	switch {
+	case erra == nil && errb == nil && na != nb:
+		return na < nb
+	case erra == nil && errb == nil:
+		return resta < restb
+	case erra == nil || errb == nil:
+		return erra == nil
+	}
+	return a < b
+}
+
+// leadingNumber splits a service into the number it starts with and the rest. ("36A" is 36 and "A")
+func leadingNumber(service string) (int, string, error) {
+	digits := len(service) - len(strings.TrimLeft(service, "0123456789"))
+	n, err := strconv.Atoi(service[:digits])
+	return n, service[digits:], err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// cache is the cache opened at startup, which the API and bots serve boards from and offline
+	// and failover boards are saved in.
+	cache Cache = NewMemoryCache(cacheTTL)
+
+	// cacheTTL is how long the API keeps boards unless the config file says otherwise.
+	cacheTTL = 30 * time.Second
+
+	// errBusy is returned when a public instance won't fetch an uncached stop right now.
+	errBusy = fmt.Errorf("%w: too busy to fetch new stops, try again shortly.", ErrRateLimited)
+)
+
+// goPublic turns on the settings for every board fetched that make an instance safe to expose
+// like wttr.in. The Server's own are turned on with its GoPublic.
+func goPublic() {
+	// Serve boards from the cache for longer.
+	cacheTTL = time.Minute
+	// Give up on a slow upstream quickly, sooner still if --fetch-timeout says so.
+	if upstreamHTTP.Timeout == 0 || upstreamHTTP.Timeout > 8*time.Second {
+		upstreamHTTP.Timeout = 8 * time.Second
+	}
+}
+
+// Boards gets boards through a cache, as the API and the bots serve them, keeping hot stops
+// fresh in the background. It's safe to use from several goroutines.
+type Boards struct {
+	// Cache keeps the boards fetched.
+	Cache Cache
+	// Upstream limits how often stops that aren't cached are fetched, or is nil for no limit.
+	Upstream *rate.Limiter
+	// Polls keeps hot stops fresh in the cache.
+	Polls *Poller
+}
+
+// NewBoards gets boards through a cache, fetching stops whenever they aren't in it.
+func NewBoards(cache Cache) *Boards {
+	return &Boards{Cache: cache, Polls: NewPoller(cache)}
+}
+
+// lookup gets the buses for a stop, going through the cache. The fetch stops when ctx is done,
+// so a client that hangs up stops hitting the upstream site.
+func (b *Boards) lookup(ctx context.Context, ref string) ([]Bus, error) {
+	// Offline, the newest saved board is all there is.
+	if offline {
+		return getBuses(ctx, ref)
+	}
+	if buses, ok := b.fresh(ref); ok {
+		return buses, nil
+	}
+	// Serve the board saved at the last shutdown while a fresh one is fetched.
+	if warm != nil {
+		if buses, ok := warm.Stale(ref); ok {
+			if warm.claim(ref) {
+				go b.refresh(ref)
+			}
+			return buses, nil
+		}
+	}
+	// Unknown stops only reach the upstream site while there's budget left.
+	if b.Upstream != nil && !b.Upstream.Allow() {
+		return []Bus{}, errBusy
+	}
+
+	buses, err := getBuses(ctx, ref)
+	if err != nil {
+		// While the upstream site struggles, an old board beats none.
+		if load.Tier() != normal && Retryable(err) {
+			if old, fetched, ok := b.Cache.Last(ref); ok && len(old) > 0 {
+				return markStale(old, fetched), nil
+			}
+		}
+		return buses, err
+	}
+	// Don't keep a saved board from a failover, so the next request tries the upstream again.
+	if !isStale(buses) {
+		b.Cache.Put(ref, buses)
+	}
+	return buses, nil
+}
+
+// fresh returns a stop's cached board while it's fresh: for the cache's ttl, or a little longer
+// than its polling interval for polled stops, so it's replaced before it runs out.
+func (b *Boards) fresh(ref string) ([]Bus, bool) {
+	every, polled := b.Polls.Every(ref)
+	if !polled {
+		return b.Cache.Get(ref)
+	}
+	buses, fetched, ok := b.Cache.Last(ref)
+	if !ok || time.Since(fetched) > load.Stretch(every+every/2) {
+		return nil, false
+	}
+	return buses, true
+}
+
+// refresh fetches a stop in the background and caches it.
+func (b *Boards) refresh(ref string) {
+	defer warm.release(ref)
+	buses, err := getBuses(context.Background(), ref)
+	if err != nil {
+		log.Println("warm:", ref, err)
+		return
+	}
+	if !isStale(buses) {
+		b.Cache.Put(ref, buses)
+	}
+}
+
+// limiter rate limits clients by IP address.
+type limiter struct {
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+// client is the rate limit of a single IP address.
+type client struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// newLimiter creates a limiter and forgets idle clients in the background.
+func newLimiter() *limiter {
+	l := &limiter{clients: map[string]*client{}}
+	go func() {
+		for range time.Tick(time.Minute) {
+			l.mu.Lock()
+			for ip, c := range l.clients {
+				if time.Since(c.seen) > 3*time.Minute {
+					delete(l.clients, ip)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+	return l
+}
+
+// allow checks if an IP address may make another request.
+func (l *limiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.clients[ip]
+	if !ok {
+		// One request a second, with bursts of 10.
+		c = &client{limiter: rate.NewLimiter(rate.Every(time.Second), 10)}
+		l.clients[ip] = c
+	}
+	c.seen = time.Now()
+	return c.limiter.Allow()
+}
+
+// Limit wraps a handler, rejecting clients that make too many requests.
+func (l *limiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !l.allow(ip) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(429)
+			w.Write([]byte(tooMany))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
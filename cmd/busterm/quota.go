@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyConfig describes an API key and its limits.
+type KeyConfig struct {
+	// Key is the secret clients send in X-API-Key or ?key=.
+	Key string `toml:"key"`
+	// Name identifies the key holder in usage reports.
+	Name string `toml:"name"`
+	// Rate is the number of requests a second, with bursts of twice that. (0 = unlimited)
+	Rate float64 `toml:"rate"`
+	// Daily and Monthly cap the requests per UTC day and month. (0 = unlimited)
+	Daily   int `toml:"daily"`
+	Monthly int `toml:"monthly"`
+}
+
+// keyUsage counts the requests made with a key.
+type keyUsage struct {
+	config  KeyConfig
+	bucket  *rate.Limiter
+	day     string
+	month   string
+	daily   int
+	monthly int
+}
+
+// Quotas enforces rate limits and quotas per API key.
+type Quotas struct {
+	mu   sync.Mutex
+	keys map[string]*keyUsage
+}
+
+// NewQuotas sets up the quotas of the configured keys.
+func NewQuotas(keys []KeyConfig) *Quotas {
+	q := &Quotas{keys: map[string]*keyUsage{}}
+	for _, key := range keys {
+		u := &keyUsage{config: key}
+		if key.Rate > 0 {
+			u.bucket = rate.NewLimiter(rate.Limit(key.Rate), int(2*key.Rate)+1)
+		}
+		q.keys[key.Key] = u
+	}
+	return q
+}
+
+// Replace takes new keys and limits, when the config file is reloaded. Keys that are kept
+// keep what they've used so far.
+func (q *Quotas) Replace(keys []KeyConfig) {
+	fresh := NewQuotas(keys)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, u := range fresh.keys {
+		if old, ok := q.keys[key]; ok {
+			u.day, u.month, u.daily, u.monthly = old.day, old.month, old.daily, old.monthly
+			if old.config.Rate == u.config.Rate {
+				u.bucket = old.bucket
+			}
+		}
+	}
+	q.keys = fresh.keys
+}
+
+// roll starts counting afresh when a new day or month begins.
+func (u *keyUsage) roll(now time.Time) {
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+	if u.day != day {
+		u.day, u.daily = day, 0
+	}
+	if u.month != month {
+		u.month, u.monthly = month, 0
+	}
+}
+
+// take counts a request against a key, returning the error to send if it's over its limits.
+func (q *Quotas) take(key string) (int, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.keys[key]
+	if !ok {
+		return 401, unauthorized
+	}
+	u.roll(time.Now().UTC())
+	if (u.config.Daily > 0 && u.daily >= u.config.Daily) || (u.config.Monthly > 0 && u.monthly >= u.config.Monthly) {
+		return 429, overQuota
+	}
+	if u.bucket != nil && !u.bucket.Allow() {
+		return 429, tooMany
+	}
+	u.daily++
+	u.monthly++
+	return 200, ""
+}
+
+// Enforce wraps a handler, rejecting requests without a known key or over their quota.
+// Checking usage doesn't count towards it.
+func (q *Quotas) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/usage" {
+			if status, body := q.take(apiKey(r)); status != 200 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				w.Write([]byte(body))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Usage is the usage report of a key.
+type Usage struct {
+	Name    string `json:"name"`
+	Daily   Quota  `json:"daily"`
+	Monthly Quota  `json:"monthly"`
+}
+
+// Quota is how much of a quota has been used. A limit of 0 means unlimited.
+type Quota struct {
+	Used   int       `json:"used"`
+	Limit  int       `json:"limit"`
+	Resets time.Time `json:"resets"`
+}
+
+// Name returns the name a key was given in the config file.
+func (q *Quotas) Name(key string) (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.keys[key]
+	if !ok {
+		return "", false
+	}
+	return u.config.Name, true
+}
+
+// Usage reports how much of its quotas a key has used.
+func (q *Quotas) Usage(key string) (Usage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.keys[key]
+	if !ok {
+		return Usage{}, false
+	}
+	now := time.Now().UTC()
+	u.roll(now)
+	y, m, d := now.Date()
+	return Usage{
+		Name:    u.config.Name,
+		Daily:   Quota{Used: u.daily, Limit: u.config.Daily, Resets: time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)},
+		Monthly: Quota{Used: u.monthly, Limit: u.config.Monthly, Resets: time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)},
+	}, true
+}
+
+// serveUsage answers /v1/usage with the usage of the caller's key.
+func (s *Server) serveUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.Quotas == nil {
+		w.WriteHeader(404)
+		w.Write([]byte(noKeys))
+		return
+	}
+	report, ok := s.Quotas.Usage(apiKey(r))
+	if !ok {
+		w.WriteHeader(401)
+		w.Write([]byte(unauthorized))
+		return
+	}
+	if report.Daily.Limit > 0 {
+		w.Header().Set("X-Quota-Remaining", strconv.Itoa(report.Daily.Limit-report.Daily.Used))
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(data)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// regions are the ACIS Connect deployments busterm knows, selectable with --region, from
+// regions.toml. Others can be used with --base-url.
+func regions() (map[string]string, error) {
+	data, err := readAsset("regions.toml")
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]string{}
+	if err := toml.Unmarshal(data, &known); err != nil {
+		return nil, errors.New("regions.toml: " + err.Error())
+	}
+	return known, nil
+}
+
+// acisURL picks the departure page for ACIS: --base-url, then --region, then the config file's
+// base_url and region, then Yorkshire.
+func (config Config) acisURL(flagURL string, flagRegion string) (string, error) {
+	switch {
+	case flagURL != "":
+		return flagURL, nil
+	case flagRegion != "":
+		return regionURL(flagRegion)
+	case config.BaseURL != "":
+		return config.BaseURL, nil
+	case config.Region != "":
+		return regionURL(config.Region)
+	}
+	return baseurl, nil
+}
+
+// regionURL returns the departure page of a region.
+func regionURL(region string) (string, error) {
+	urls, err := regions()
+	if err != nil {
+		return "", err
+	}
+	if url, ok := urls[strings.ToLower(region)]; ok {
+		return url, nil
+	}
+	known := []string{}
+	for name := range urls {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	return "", errors.New("unknown region: " + region + " (expected " + strings.Join(known, ", ") + ", or use --base-url)")
+}
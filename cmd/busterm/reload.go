@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReloadOnHangup reloads the config file whenever the API server gets SIGHUP, so a fleet can be
+// reconfigured without dropping connections. API keys and their limits, the allow and deny lists,
+// stop notes, the stops kept warm and polled stops are reloaded; anything else needs a restart.
+func (s *Server) ReloadOnHangup(path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := s.Reload(path); err != nil {
+				log.Println("reload:", err)
+				continue
+			}
+			log.Println("reload: config file reloaded")
+		}
+	}()
+}
+
+// Reload reads the config file again and swaps in what can change while serving. A config file
+// with a mistake in it changes nothing.
+func (s *Server) Reload(path string) error {
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	// API keys and the allow and deny lists wrap the server when it starts.
+	if (s.Quotas != nil) != (len(config.API.Keys) > 0) {
+		return errors.New("turning API keys on or off needs a restart.")
+	}
+	hasACL := len(config.API.Allow) > 0 || len(config.API.Deny) > 0
+	if (s.Access != nil) != hasACL {
+		return errors.New("turning the allow and deny lists on or off needs a restart.")
+	}
+	var acl *ACL
+	if hasACL {
+		if acl, err = NewACL(config.API.Allow, config.API.Deny); err != nil {
+			return err
+		}
+	}
+	every, err := parsePolls(config.API.Poll)
+	if err != nil {
+		return err
+	}
+
+	if s.Quotas != nil {
+		s.Quotas.Replace(config.API.Keys)
+	}
+	if s.Access != nil {
+		s.Access.Replace(acl)
+	}
+	notes.SetFixed(config.Notes)
+	if warm != nil {
+		warm.SetStops(config.Stops...)
+	}
+	if replay == nil && !offline {
+		for _, ref := range config.Stops {
+			go s.lookup(context.Background(), ref)
+		}
+		s.Polls.Set(every)
+	}
+	return nil
+}
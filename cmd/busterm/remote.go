@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Remote fetches departures from another busterm's API instead of the upstream site, so thin
+// clients on restricted networks share a central instance's cache and credentials.
+type Remote struct {
+	// Server is the base URL of the busterm API. (https://bus.example.com)
+	Server string
+	// APIKey is sent in X-API-Key, for instances that take keys.
+	APIKey string
+	// HTTPClient makes the requests, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (r Remote) String() string {
+	return "remote"
+}
+
+// Capabilities of a remote busterm depend on its source, which could be any of them.
+func (r Remote) Capabilities() Capabilities {
+	c := Capabilities{}
+	for _, name := range sources {
+		if name != "remote" {
+			c = c.Or(capabilitiesOf(sourceProvider(name)))
+		}
+	}
+	return c
+}
+
+// CheckStop accepts ATCO codes as well as NapTAN ones, as the server may use any source.
+// The server checks them properly.
+func (r Remote) CheckStop(ref string) error {
+	return checkATCO(ref)
+}
+
+// FetchDepartures asks the busterm API for the departures from a stop. They come back already
+// named and cleaned up by the server.
+func (r Remote) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	u := strings.TrimSuffix(r.Server, "/") + "/check_buses?naptan=" + url.QueryEscape(ref)
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return []Bus{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("X-API-Key", r.APIKey)
+	}
+	res, err := orUpstream(r.HTTPClient).Do(req)
+	if err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		// The API explains itself with {"error": "..."}.
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		if body.Error == "" {
+			body.Error = res.Status
+		}
+		switch res.StatusCode {
+		case 400:
+			return []Bus{}, fmt.Errorf("%w: the busterm server says %s", ErrInvalidStop, body.Error)
+		case 429, 503:
+			return []Bus{}, fmt.Errorf("%w: the busterm server says %s", ErrRateLimited, body.Error)
+		case 500:
+			return []Bus{}, fmt.Errorf("%w: the busterm server says %s", ErrParse, body.Error)
+		}
+		return []Bus{}, fmt.Errorf("%w: the busterm server says %s", ErrUpstreamDown, body.Error)
+	}
+
+	buses := []Bus{}
+	if err := json.NewDecoder(res.Body).Decode(&buses); err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	return buses, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+var (
+	// retries is how many more times a fetch that may work later is tried. (--retries)
+	retries int
+
+	// retryBase is how long the first retry waits, doubling each time after.
+	retryBase = 500 * time.Millisecond
+
+	// retryMax is the longest a retry waits, however many came before it.
+	retryMax = 30 * time.Second
+)
+
+// fetchRetrying fetches a stop from the provider, trying again with jittered exponential backoff when
+// the upstream site is down, slow or rate limited. Every try counts towards the load, and there
+// are no retries when the site was already struggling, so busterm doesn't add to its troubles.
+func fetchRetrying(ctx context.Context, ref string) ([]Bus, error) {
+	struggling := load.Tier() != normal
+	for try := 0; ; try++ {
+		start := time.Now()
+		buses, err := provider.FetchDepartures(ctx, ref)
+		// A caller that gave up isn't the upstream site's fault.
+		if ctx.Err() != nil {
+			return []Bus{}, ctx.Err()
+		}
+		err = Timeout(err)
+		load.Observe(time.Since(start), err)
+		if err == nil || try >= retries || !Retryable(err) || struggling {
+			return buses, err
+		}
+
+		select {
+		case <-time.After(backoff(try)):
+		case <-ctx.Done():
+			return []Bus{}, ctx.Err()
+		}
+	}
+}
+
+// backoff is how long to wait before a retry: a random time up to twice the last one's limit,
+// so many clients failing at once don't all come back together. No limit is above retryMax.
+func backoff(try int) time.Duration {
+	limit := retryMax
+	// Past a few tries the shift would overflow, and the limit is capped anyway.
+	if try < 16 {
+		limit = min(retryBase<<try, retryMax)
+	}
+	return limit/2 + time.Duration(rand.Int63n(int64(limit/2)+1))
+}
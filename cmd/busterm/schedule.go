@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// Job is a board query run on a cron schedule by `busterm schedule`.
+type Job struct {
+	// Cron is a 5 field cron expression ("30 7 * * 1-5") or a descriptor such as "@hourly".
+	Cron string `toml:"cron"`
+	// Naptan is the stop to fetch.
+	Naptan string `toml:"naptan"`
+	// Service only keeps these services, comma separated. (empty = all)
+	Service string `toml:"service"`
+	// Towards only keeps buses heading to this destination group. (empty = all)
+	Towards string `toml:"towards"`
+	// Profile renders the board. (empty = default)
+	Profile string `toml:"profile"`
+	// Command receives the board on stdin, such as "mail -s 'Buses' me@example.com". (empty = stdout)
+	Command string `toml:"command"`
+}
+
+// schedule runs the jobs from the config file until busterm is stopped.
+func schedule(config Config) error {
+	if len(config.Schedule) == 0 {
+		return errors.New("no [[schedule]] jobs in the config file.")
+	}
+
+	runner := cron.New()
+	for i, job := range config.Schedule {
+		run, err := config.prepare(job)
+		if err != nil {
+			return fmt.Errorf("schedule %d: %s", i+1, err)
+		}
+		if _, err := runner.AddFunc(job.Cron, run); err != nil {
+			return fmt.Errorf("schedule %d: %s", i+1, err)
+		}
+	}
+
+	fmt.Printf("busterm is running %d scheduled jobs\n", len(config.Schedule))
+	runner.Run()
+	return nil
+}
+
+// prepare checks a job and returns the function that runs it.
+func (config Config) prepare(job Job) (func(), error) {
+	job.Naptan = resolveAlias(job.Naptan)
+	if err := checkCode(job.Naptan); err != nil {
+		return nil, err
+	}
+
+	name := job.Profile
+	if name == "" {
+		name = "default"
+	}
+	p, err := config.profile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := Filter{Services: splitList(job.Service)}
+	if job.Towards != "" {
+		f.Group, f.Destinations, err = config.group(job.Towards)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		buses, err := getBuses(context.Background(), job.Naptan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", job.Naptan, err)
+			return
+		}
+
+		// Render the board, without colours when it's going to another program.
+		var board bytes.Buffer
+		var c clif.Output = clif.NewColorOutput(&board)
+		if p.Monochrome || job.Command != "" {
+			c = clif.NewMonochromeOutput(&board)
+		}
+		renderTable(c, p, f, f.Apply(buses), job.Naptan)
+
+		if job.Command == "" {
+			os.Stdout.Write(board.Bytes())
+			return
+		}
+		cmd := exec.Command("sh", "-c", job.Command)
+		cmd.Stdin = &board
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", job.Command, err)
+		}
+	}, nil
+}
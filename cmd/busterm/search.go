@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/return/busterm/pkg/render"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// match is a stop found by a search, and how well it matched.
+type match struct {
+	stop  *indexed
+	score int
+}
+
+// better ranks matches: higher scores first, then by name.
+func (m match) better(than match) bool {
+	if m.score != than.score {
+		return m.score > than.score
+	}
+	return m.stop.title < than.stop.title
+}
+
+// indexed is a stop with its words ready for searching.
+type indexed struct {
+	stop  Stop
+	title string
+	name  []string
+	place []string
+}
+
+var (
+	// indexOnce guards building index.
+	indexOnce sync.Once
+	// index is every stop, split into words once so searching as you type is quick.
+	index    []indexed
+	indexErr error
+)
+
+// searchIndex builds the search index the first time it's needed.
+func searchIndex() ([]indexed, error) {
+	indexOnce.Do(func() {
+		indexErr = eachStop(func(s Stop) bool {
+			index = append(index, indexed{s, s.Title(), words(s.Name + " " + s.Indicator), words(s.Street + " " + s.Locality)})
+			return true
+		})
+	})
+	return index, indexErr
+}
+
+// searchStops finds the NaPTAN stops best matching a query such as "Leeds City Square", best first.
+// Every word of the query has to match the start of a word of the stop's name, street or locality,
+// allowing a typo in longer words. Words in the name count for more.
+func searchStops(query string, limit int) ([]Stop, error) {
+	want := words(query)
+	if len(want) == 0 {
+		return nil, errors.New("search for a stop name, such as \"Leeds City Square\".")
+	}
+	stops, err := searchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the best matches, in order, as short queries match most stops.
+	best := []match{}
+next:
+	for i := range stops {
+		m := match{stop: &stops[i]}
+		for _, w := range want {
+			score := max(3*wordScore(w, m.stop.name), wordScore(w, m.stop.place))
+			if score == 0 {
+				continue next
+			}
+			m.score += score
+		}
+		if len(best) == limit && !m.better(best[limit-1]) {
+			continue
+		}
+		at := sort.Search(len(best), func(j int) bool { return m.better(best[j]) })
+		best = append(best, match{})
+		copy(best[at+1:], best[at:])
+		best[at] = m
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+
+	found := []Stop{}
+	for _, m := range best {
+		found = append(found, m.stop.stop)
+	}
+	return found, nil
+}
+
+// wordScore scores a query word against the words of a stop: 3 for a whole word, 2 for the start
+// of one and 1 for a word a typo away.
+func wordScore(w string, in []string) int {
+	score := 0
+	for _, word := range in {
+		switch {
+		case word == w:
+			return 3
+		case strings.HasPrefix(word, w):
+			score = max(score, 2)
+		case len(w) >= 4 && oneTypo(w, word):
+			score = max(score, 1)
+		}
+	}
+	return score
+}
+
+// words splits text into lower case words for searching, without accents or punctuation.
+// ("St. James's" is "st", "james", "s")
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(ascii(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// oneTypo reports if two words are at most one letter added, removed or changed apart.
+func oneTypo(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+	// Skip the common start, then the rest must match after the typo.
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		return a[min(i+1, len(a)):] == b[min(i+1, len(b)):]
+	}
+	return a[i:] == b[i+1:]
+}
+
+// printSearch prints the stops matching a query, with the codes to use with -n.
+func printSearch(c clif.Output, query string, limit int) error {
+	stops, err := searchStops(query, limit)
+	if err != nil {
+		return err
+	}
+	if len(stops) == 0 {
+		if err := checkStops(); err != nil {
+			return err
+		}
+		return errors.New("no stops match " + query + ".")
+	}
+	rows := [][]string{}
+	for _, s := range stops {
+		// Stops without a NapTAN code can still be used with sources that take ATCO codes.
+		code := s.Naptan
+		if code == "" {
+			code = s.ATCO
+		}
+		rows = append(rows, []string{code, "<headline>" + s.Title() + "<reset>", s.Street, s.Locality, s.Bearing})
+	}
+	c.Printf("%s\n", render.Columns([]string{"Code", "Stop", "Street", "Locality", "Bearing"}, rows, 0))
+	return nil
+}
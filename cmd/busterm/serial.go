@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.bug.st/serial"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Framing bytes of serial packets.
+const (
+	stx = 0x02
+	etx = 0x03
+)
+
+// serialSink writes each board as a framed plain-text packet to a serial device,
+// for Arduino/ESP driven LED matrix signs.
+//
+// A packet is STX, the lines of the board separated by LF, ETX and then a checksum byte,
+// the XOR of every byte between STX and ETX. Lines are plain ASCII, exactly width characters:
+// "36 Harrogate  5m".
+type serialSink struct {
+	port  serial.Port
+	width int
+	rows  int
+}
+
+// openSerial opens a serial output such as "/dev/ttyUSB0?baud=9600&format=8N1&width=16&rows=2".
+func openSerial(spec string) (Sink, error) {
+	device, rawQuery, _ := strings.Cut(spec, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	setting := func(name string, fallback int) (int, error) {
+		value := query.Get(name)
+		if value == "" {
+			return fallback, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, errors.New("serial " + name + " must be a positive number.")
+		}
+		return n, nil
+	}
+
+	mode := &serial.Mode{}
+	if mode.BaudRate, err = setting("baud", 9600); err != nil {
+		return nil, err
+	}
+	if err := serialFormat(mode, query.Get("format")); err != nil {
+		return nil, err
+	}
+	s := serialSink{}
+	if s.width, err = setting("width", 16); err != nil {
+		return nil, err
+	}
+	if s.rows, err = setting("rows", 2); err != nil {
+		return nil, err
+	}
+
+	s.port, err = serial.Open(device, mode)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serialFormat sets the data bits, parity and stop bits from a format such as "8N1". (the default)
+func serialFormat(mode *serial.Mode, format string) error {
+	if format == "" {
+		format = "8N1"
+	}
+	invalid := errors.New("serial format must look like 8N1: data bits, parity (N, E or O) and stop bits.")
+	if len(format) != 3 {
+		return invalid
+	}
+	switch format[0] {
+	case '5', '6', '7', '8':
+		mode.DataBits = int(format[0] - '0')
+	default:
+		return invalid
+	}
+	switch unicode.ToUpper(rune(format[1])) {
+	case 'N':
+		mode.Parity = serial.NoParity
+	case 'E':
+		mode.Parity = serial.EvenParity
+	case 'O':
+		mode.Parity = serial.OddParity
+	default:
+		return invalid
+	}
+	switch format[2] {
+	case '1':
+		mode.StopBits = serial.OneStopBit
+	case '2':
+		mode.StopBits = serial.TwoStopBits
+	default:
+		return invalid
+	}
+	return nil
+}
+
+// Send writes the first rows of the board as a packet.
+func (s serialSink) Send(snap Snapshot) error {
+	lines := []string{}
+	for _, bus := range snap.Buses {
+		if len(lines) == s.rows {
+			break
+		}
+		lines = append(lines, signLine(bus, s.width))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("%-*s", s.width, "No buses"))
+	}
+
+	payload := []byte(strings.Join(lines, "\n"))
+	var checksum byte
+	for _, b := range payload {
+		checksum ^= b
+	}
+	packet := append([]byte{stx}, payload...)
+	packet = append(packet, etx, checksum)
+	_, err := s.port.Write(packet)
+	return err
+}
+
+// signLine fits a bus onto one line of a sign: the service and destination on the left,
+// cut short if need be, and the time on the right.
+func signLine(bus Bus, width int) string {
+	// "5 mins" is "5m".
+	when := bus.When()
+	if !bus.Expected.IsZero() && !bus.Due && !bus.ClockTime() {
+		when = strconv.Itoa(bus.Minutes) + "m"
+	}
+
+	left := ascii(bus.Service + " " + bus.To)
+	room := width - len(when) - 1
+	if room < 0 {
+		room = 0
+	}
+	if len(left) > room {
+		left = left[:room]
+	}
+	line := fmt.Sprintf("%-*s %s", room, left, ascii(when))
+	if len(line) > width {
+		line = line[:width]
+	}
+	return line
+}
+
+// ascii turns text into plain ASCII for signs without other fonts. Accents are dropped ("é" is "e")
+// and anything else becomes "?".
+func ascii(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// A combining accent, dropped.
+		case r < 0x20 || r == 0x7f:
+			// Control characters would break the framing.
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/ukautz/clif.v1"
+)
+
+// Server is the API server. It holds what its handlers share with the background polls, warm
+// refreshes and config reloads, each part guarding itself, so they can all run at once.
+type Server struct {
+	*Boards
+	// Port is the port to listen on. (7654)
+	Port string
+	// Public listens everywhere rather than on localhost, rate limits clients, drops slow
+	// connections and refuses to change stops. Turn it on with GoPublic.
+	Public bool
+	// Quotas holds API keys to their limits, or is nil when no key is needed.
+	Quotas *Quotas
+	// Access lets in the addresses allowed, or is nil to let everyone in.
+	Access *ACL
+	// Auditor logs every request, or is nil.
+	Auditor *Auditor
+	// Logger logs every request as it comes in.
+	Logger *log.Logger
+}
+
+// NewServer makes an API server for boards, listening on localhost.
+func NewServer(boards *Boards, port string) *Server {
+	return &Server{Boards: boards, Port: port, Logger: log.New(os.Stdout, "", log.Ldate)}
+}
+
+// GoPublic makes the server safe to expose like wttr.in. Stops nobody has asked for recently
+// share a small upstream budget.
+func (s *Server) GoPublic() {
+	s.Public = true
+	s.Upstream = rate.NewLimiter(rate.Every(2*time.Second), 10)
+}
+
+// ListenAndServe serves the API until it fails.
+func (s *Server) ListenAndServe() error {
+	// Listen on port :7654
+	// TODO: For production usecases change 'localhost' to 7654.
+	// Only do this when deploying on a real server.
+	server := &http.Server{Addr: "localhost:" + s.Port, Handler: s.Handler()}
+
+	// Public instances listen everywhere and drop slow connections.
+	if s.Public {
+		server.Addr = ":" + s.Port
+		server.ReadHeaderTimeout = 5 * time.Second
+		server.ReadTimeout = 10 * time.Second
+		server.WriteTimeout = 15 * time.Second
+		server.IdleTimeout = time.Minute
+		server.MaxHeaderBytes = 16 << 10
+	}
+
+	fmt.Println("busterm API is up on port :" + s.Port)
+	return server.ListenAndServe()
+}
+
+// Handler routes the API's requests through its middleware.
+func (s *Server) Handler() http.Handler {
+	// The API's own routes, so nothing a library registers on the default mux is served.
+	mux := http.NewServeMux()
+	// Create /check_buses route for our server.
+	mux.HandleFunc("/check_buses", func(w http.ResponseWriter, r *http.Request) {
+		// Add headers.
+		w.Header().Add("Accept", "application/json")
+		w.Header().Add("Content-Type", "application/json")
+		s.Logger.Println(r.Method, r.Host, r.RequestURI) // GET (host) endpoint/params
+
+		// Get the naptan code.
+		code := resolveAlias(r.URL.Query().Get("naptan"))
+		err := checkCode(code)
+		if err != nil {
+			w.WriteHeader(400)
+			fmt.Fprint(w, invalidNaptan)
+			return
+		}
+
+		// Get Buses, giving up if the client does.
+		buses, err := s.lookup(r.Context(), code)
+		if err != nil {
+			// Let clients know when it's worth trying again.
+			if Retryable(err) {
+				w.Header().Set("Retry-After", "30")
+			}
+			status, body := errorStatus(err)
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+
+		// Turn buses into JSON, only the ones asked for. (?service=36,X84&exclude=110&dest=leeds&limit=5&sort=service)
+		data, err := json.Marshal(requestFilter(r).Apply(buses))
+		if err != nil {
+			w.WriteHeader(400)
+			fmt.Fprint(w, unable)
+			return
+		}
+		w.WriteHeader(200)
+		w.Write(data)
+		return
+	})
+
+	// Create the providers route, so UIs know which columns can ever be filled.
+	mux.HandleFunc("GET /v1/providers", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		serveProviders(w)
+	})
+
+	// Create the usage route, so key holders can check their quotas.
+	mux.HandleFunc("GET /v1/usage", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveUsage(w, r)
+	})
+
+	// Create the plain text board route for dumb clients (curl, serial displays).
+	mux.HandleFunc("GET /v1/stops/{naptan}/board.txt", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveBoard(r.Context(), w, r.PathValue("naptan"), requestFilter(r), clif.NewMonochromeOutput)
+	})
+
+	// Create the summary route, a sentence for voice assistants and screen readers.
+	mux.HandleFunc("GET /v1/stops/{naptan}/summary", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveSummary(r.Context(), w, r.PathValue("naptan"), requestFilter(r))
+	})
+
+	// Create the voice assistant routes, answering with the summary.
+	mux.HandleFunc("POST /v1/assistant/alexa", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveAlexa(w, r)
+	})
+	mux.HandleFunc("POST /v1/assistant/google", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveGoogle(w, r)
+	})
+
+	// Create the stop route, with the stop's name and notes.
+	mux.HandleFunc("GET /v1/stops/{naptan}", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		serveStop(w, r.PathValue("naptan"))
+	})
+
+	// Create the route for adding notes to a stop. ("shelter broken")
+	mux.HandleFunc("POST /v1/stops/{naptan}/notes", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveAddNote(w, r, r.PathValue("naptan"))
+	})
+
+	// Create the colourised board route, for `curl | head` in a terminal.
+	mux.HandleFunc("GET /v1/stops/{naptan}/board.ansi", func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Println(r.Method, r.Host, r.RequestURI)
+		s.serveBoard(r.Context(), w, r.PathValue("naptan"), requestFilter(r), clif.NewColorOutput)
+	})
+
+	var handler http.Handler = mux
+
+	// Say when boards are kept longer because the upstream site is struggling.
+	handler = load.Annotate(handler)
+
+	// Hold API keys to their rate limits and quotas.
+	if s.Quotas != nil {
+		handler = s.Quotas.Enforce(handler)
+	}
+
+	// Public instances rate limit clients.
+	if s.Public {
+		handler = newLimiter().Limit(handler)
+	}
+
+	// Reject addresses outside the allow list before anything else.
+	if s.Access != nil {
+		handler = s.Access.Check(handler)
+	}
+
+	// Audit every request, including the ones turned away.
+	if s.Auditor != nil {
+		handler = s.Auditor.Audit(handler, s.Quotas)
+	}
+	return handler
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubProvider answers every stop with the same two buses, counting its fetches.
+type stubProvider struct {
+	fetches atomic.Int64
+}
+
+func (p *stubProvider) FetchDepartures(ctx context.Context, stopRef string) ([]Bus, error) {
+	p.fetches.Add(1)
+	now := time.Now()
+	return []Bus{
+		{Service: "36", To: "Leeds", Time: "5 mins", Expected: now.Add(5 * time.Minute), Minutes: 5},
+		{Service: "X84", To: "Otley", Time: "12 mins", Expected: now.Add(12 * time.Minute), Minutes: 12},
+	}, nil
+}
+
+// TestServerReload serves requests on every route while the config file is reloaded and stops
+// are polled, so go test -race can catch state shared without a lock.
+func TestServerReload(t *testing.T) {
+	stub := &stubProvider{}
+	defer func(p Provider, interval time.Duration) { provider, minInterval = p, interval }(provider, minInterval)
+	provider, minInterval = stub, 10*time.Millisecond
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "busterm.toml")
+	writeConfig := func(i int) {
+		config := fmt.Sprintf(`stops = ["45010687"]
+
+[notes]
+"45010687" = ["Shelter broken %d"]
+
+[api.poll]
+"45010688" = "%dms"
+`, i, 10+i%3*10)
+		if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(0)
+
+	server := NewServer(NewBoards(NewMemoryCache(20*time.Millisecond)), "0")
+	server.Logger = log.New(io.Discard, "", 0)
+	if err := server.Reload(path); err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+	defer server.Polls.Set(nil)
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{"GET", "/check_buses?naptan=45010687", ""},
+		{"GET", "/check_buses?naptan=45010688", ""},
+		{"GET", "/v1/stops/45010687/board.txt", ""},
+		{"GET", "/v1/stops/45010688/board.ansi", ""},
+		{"GET", "/v1/stops/45010687/summary", ""},
+		{"GET", "/v1/stops/45010687", ""},
+		{"GET", "/v1/usage", ""},
+		{"POST", "/v1/stops/45010689/notes", "Bin overflowing"},
+		{"POST", "/v1/assistant/alexa?naptan=45010688", "{}"},
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+			writeConfig(i)
+			if err := server.Reload(path); err != nil {
+				t.Error("reload:", err)
+				return
+			}
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for c := 0; c < 8; c++ {
+		clients.Add(1)
+		go func(c int) {
+			defer clients.Done()
+			for i := 0; i < 40; i++ {
+				request := requests[(c+i)%len(requests)]
+				req, err := http.NewRequest(request.method, api.URL+request.path, strings.NewReader(request.body))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				io.Copy(io.Discard, res.Body)
+				res.Body.Close()
+				// Usage is 404 without keys; everything else must be served.
+				if res.StatusCode >= 500 || (res.StatusCode >= 400 && request.path != "/v1/usage") {
+					t.Errorf("%s %s: %d", request.method, request.path, res.StatusCode)
+				}
+			}
+		}(c)
+	}
+	clients.Wait()
+	close(done)
+	wg.Wait()
+
+	if stub.fetches.Load() == 0 {
+		t.Fatal("no stop was ever fetched")
+	}
+}
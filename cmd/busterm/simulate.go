@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// clock returns the current time. Simulations replace it with a replayed clock.
+	clock = time.Now
+
+	// replay is the running simulation, if any.
+	replay *Simulation
+)
+
+// Snapshot is a departure board recorded at a point in time.
+type Snapshot struct {
+	Time  time.Time `json:"time"`
+	Ref   string    `json:"ref"`
+	Buses []Bus     `json:"buses"`
+}
+
+// record appends a snapshot to a history file as a JSON line.
+func record(path string, ref string, buses []Bus, at time.Time) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(Snapshot{Time: at, Ref: ref, Buses: buses})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// loadHistory reads every snapshot from a history file.
+func loadHistory(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	history := []Snapshot{}
+	scanner := bufio.NewScanner(f)
+	// Busy stops make for long lines.
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snap); err != nil {
+			return nil, err
+		}
+		history = append(history, snap)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, errors.New("history file has no snapshots.")
+	}
+	return history, nil
+}
+
+// parseSpeed parses a replay speed such as "10x" or "2.5".
+func parseSpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, errors.New("speed must be a positive number such as 10x.")
+	}
+	return speed, nil
+}
+
+// Simulation replays a recorded history at an accelerated speed, looping at the end.
+type Simulation struct {
+	history []Snapshot
+	speed   float64
+	started time.Time
+}
+
+// NewSimulation starts replaying a history from its first snapshot.
+func NewSimulation(history []Snapshot, speed float64) *Simulation {
+	return &Simulation{history: history, speed: speed, started: time.Now()}
+}
+
+// Now returns the simulated time.
+func (s *Simulation) Now() time.Time {
+	first := s.history[0].Time
+	span := s.history[len(s.history)-1].Time.Sub(first)
+	elapsed := time.Duration(float64(time.Since(s.started)) * s.speed)
+	// Start again once we reach the end of the recording.
+	if span > 0 {
+		elapsed %= span + time.Second
+	}
+	return first.Add(elapsed)
+}
+
+// Ref returns the stop reference of the first snapshot.
+func (s *Simulation) Ref() string {
+	return s.history[0].Ref
+}
+
+// Buses returns the latest recorded buses for a stop at the simulated time.
+func (s *Simulation) Buses(ref string) ([]Bus, error) {
+	now := s.Now()
+	var found *Snapshot
+	for i := range s.history {
+		snap := &s.history[i]
+		if snap.Ref != ref {
+			continue
+		}
+		if found != nil && snap.Time.After(now) {
+			break
+		}
+		found = snap
+	}
+	if found == nil {
+		return []Bus{}, errors.New("no recorded departures for stop " + ref)
+	}
+	// Hand out a copy so callers can annotate the buses.
+	return copyBuses(found.Buses), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Sink receives the latest snapshot of the board after every refresh.
+type Sink interface {
+	Send(snap Snapshot) error
+}
+
+// sink is where watch mode sends each snapshot, if anywhere.
+var sink Sink
+
+// openSink opens an output target such as "fifo:/tmp/busterm.fifo", "unix:/run/busterm.sock"
+// or "serial:/dev/ttyUSB0?baud=9600".
+func openSink(target string) (Sink, error) {
+	kind, path, ok := strings.Cut(target, ":")
+	if !ok || path == "" {
+		return nil, errors.New("output must look like fifo:<path>, unix:<path> or serial:<device>")
+	}
+	switch kind {
+	case "fifo":
+		return fifoSink{path: path}, nil
+	case "unix":
+		return listenUnix(path)
+	case "serial":
+		return openSerial(path)
+	}
+	return nil, errors.New("unknown output type: " + kind)
+}
+
+// fifoSink writes each snapshot as a JSON line to a named pipe made with mkfifo.
+type fifoSink struct {
+	path string
+}
+
+// Send writes the snapshot if something is reading the pipe, and skips it otherwise.
+func (f fifoSink) Send(snap Snapshot) error {
+	pipe, err := os.OpenFile(f.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		// Nobody is listening right now.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = pipe.Write(append(data, '\n'))
+	return err
+}
+
+// unixSink hands the latest snapshot to every process that connects to a Unix socket.
+type unixSink struct {
+	mu     sync.Mutex
+	latest []byte
+}
+
+// listenUnix starts serving snapshots on a Unix socket, replacing a stale one.
+func listenUnix(path string) (*unixSink, error) {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unixSink{latest: []byte("{}\n")}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			u.mu.Lock()
+			data := u.latest
+			u.mu.Unlock()
+			conn.Write(data)
+			conn.Close()
+		}
+	}()
+	return u, nil
+}
+
+// Send replaces the snapshot handed to new connections.
+func (u *unixSink) Send(snap Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.latest = append(data, '\n')
+	u.mu.Unlock()
+	return nil
+}
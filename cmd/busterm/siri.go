@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SIRI asks a SIRI Stop Monitoring (SIRI-SM) service for departures, as offered by many
+// UK local authorities, instead of scraping a web page.
+type SIRI struct {
+	// Endpoint is the URL stop monitoring requests are POSTed to.
+	Endpoint string
+	// RequestorRef identifies us to the service. Many use it as the API key.
+	RequestorRef string
+	// HTTPClient makes the requests, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (s SIRI) String() string {
+	return "siri"
+}
+
+// Capabilities of SIRI-SM: expected times, else aimed ones, and low floor buses when flagged.
+func (s SIRI) Capabilities() Capabilities {
+	return Capabilities{Realtime: true, Scheduled: true, DoubleDecker: true}
+}
+
+// CheckStop accepts ATCO codes, which most services take as the MonitoringRef.
+func (s SIRI) CheckStop(ref string) error {
+	return checkATCO(ref)
+}
+
+// siriRequest is a stop monitoring request for one stop.
+type siriRequest struct {
+	XMLName        xml.Name `xml:"http://www.siri.org.uk/siri Siri"`
+	Version        string   `xml:"version,attr"`
+	Timestamp      string   `xml:"ServiceRequest>RequestTimestamp"`
+	RequestorRef   string   `xml:"ServiceRequest>RequestorRef"`
+	StopMonitoring struct {
+		Version       string `xml:"version,attr"`
+		Timestamp     string `xml:"RequestTimestamp"`
+		MonitoringRef string `xml:"MonitoringRef"`
+	} `xml:"ServiceRequest>StopMonitoringRequest"`
+}
+
+// siriResponse is the part of a stop monitoring delivery busterm uses.
+type siriResponse struct {
+	Delivery struct {
+		Status         *bool  `xml:"Status"`
+		ErrorCondition string `xml:"ErrorCondition>Description"`
+		Visits         []struct {
+			Journey struct {
+				LineRef           string   `xml:"LineRef"`
+				PublishedLineName string   `xml:"PublishedLineName"`
+				DestinationName   string   `xml:"DestinationName"`
+				Features          []string `xml:"VehicleFeatureRef"`
+				Call              struct {
+					AimedArrival      time.Time `xml:"AimedArrivalTime"`
+					ExpectedArrival   time.Time `xml:"ExpectedArrivalTime"`
+					AimedDeparture    time.Time `xml:"AimedDepartureTime"`
+					ExpectedDeparture time.Time `xml:"ExpectedDepartureTime"`
+				} `xml:"MonitoredCall"`
+			} `xml:"MonitoredVehicleJourney"`
+		} `xml:"MonitoredStopVisit"`
+	} `xml:"ServiceDelivery>StopMonitoringDelivery"`
+}
+
+// FetchDepartures asks the SIRI-SM service for the departures from a stop.
+func (s SIRI) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	now := clock()
+	request := siriRequest{Version: "2.0", Timestamp: now.Format(time.RFC3339), RequestorRef: s.RequestorRef}
+	request.StopMonitoring.Version = "2.0"
+	request.StopMonitoring.Timestamp = request.Timestamp
+	request.StopMonitoring.MonitoringRef = ref
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return []Bus{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Endpoint, bytes.NewReader(append([]byte(xml.Header), body...)))
+	if err != nil {
+		return []Bus{}, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	res, err := orUpstream(s.HTTPClient).Do(req)
+	if err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrUpstreamDown, err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 429:
+		return []Bus{}, fmt.Errorf("%w: the SIRI service says %s", ErrRateLimited, res.Status)
+	case res.StatusCode != 200:
+		return []Bus{}, fmt.Errorf("%w: status != 200: status:%s", ErrUpstreamDown, res.Status)
+	}
+
+	var response siriResponse
+	if err := xml.NewDecoder(res.Body).Decode(&response); err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	delivery := response.Delivery
+	if delivery.Status != nil && !*delivery.Status {
+		return []Bus{}, fmt.Errorf("%w: the SIRI service says %s", ErrUpstreamDown, delivery.ErrorCondition)
+	}
+
+	// Turn each visit into a bus, leaving when it's expected to, or else when it's timetabled to.
+	departures := []departure{}
+	for _, visit := range delivery.Visits {
+		j := visit.Journey
+		bus := Bus{Service: j.PublishedLineName, To: j.DestinationName}
+		if bus.Service == "" {
+			bus.Service = j.LineRef
+		}
+		at := firstTime(j.Call.ExpectedDeparture, j.Call.AimedDeparture, j.Call.ExpectedArrival, j.Call.AimedArrival)
+		if at.IsZero() {
+			continue
+		}
+		// Without an estimate it leaves when it's timetabled to.
+		bus.DepartsAt(at, j.Call.ExpectedDeparture.IsZero() && j.Call.ExpectedArrival.IsZero(), now)
+		// Only low floor buses are flagged, the rest are taken to be double deckers like ACIS does.
+		bus.LowFloor = oneOf("lowFloor", j.Features)
+		bus.DoubleDecker = len(j.Features) > 0 && !bus.LowFloor
+		departures = append(departures, departure{bus, at})
+	}
+	return soonestFirst(departures), nil
+}
+
+// firstTime returns the first time that's set.
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// soakRound is how often a soak test goes round its stops, as often as a kiosk refreshes.
+	soakRound = 30 * time.Second
+	// soakSample is how often a soak test measures memory and goroutines, and reports progress.
+	soakSample = time.Minute
+)
+
+// sample is memory and goroutine use at a point in a soak test.
+type sample struct {
+	heap       uint64
+	goroutines int
+}
+
+// measure takes a sample, after a garbage collection so the heap is what's really kept.
+func measure() sample {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return sample{heap: m.HeapAlloc, goroutines: runtime.NumGoroutine()}
+}
+
+// Soak runs the fetch, parse and cache pipeline over and over for a long time, keeping track of
+// how memory, goroutines and errors go, to find leaks before a kiosk runs for weeks.
+type Soak struct {
+	boards  *Boards
+	mu      sync.Mutex
+	fetches int
+	errors  map[string]int
+	// settled is the sample after the first round, once connections and the cache are set up.
+	settled, last, peak sample
+}
+
+// soak gets every stop from boards each round, with up to concurrency fetches at once, for as long as the
+// duration, printing progress every sample.
+func soak(boards *Boards, stops []string, concurrency int, duration time.Duration) *Soak {
+	s := &Soak{boards: boards, errors: map[string]int{}}
+	done := time.After(duration)
+	round := time.NewTicker(soakRound)
+	defer round.Stop()
+	progress := time.NewTicker(soakSample)
+	defer progress.Stop()
+
+	start := time.Now()
+	s.round(stops, concurrency)
+	s.settled = measure()
+	s.peak = s.settled
+	for {
+		select {
+		case <-round.C:
+			s.round(stops, concurrency)
+		case <-progress.C:
+			s.observe(measure())
+			fmt.Println(s.progress(time.Since(start)))
+		case <-done:
+			s.observe(measure())
+			return s
+		}
+	}
+}
+
+// round looks every stop up once, as the API does: from the cache while it's fresh.
+func (s *Soak) round(stops []string, concurrency int) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				_, err := s.boards.lookup(context.Background(), ref)
+				s.mu.Lock()
+				s.fetches++
+				if err != nil {
+					s.errors[errorKind(err)]++
+				}
+				s.mu.Unlock()
+			}
+		}()
+	}
+	for _, ref := range stops {
+		jobs <- ref
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// observe keeps a sample as the latest, and the peak.
+func (s *Soak) observe(m sample) {
+	s.last = m
+	s.peak.heap = max(s.peak.heap, m.heap)
+	s.peak.goroutines = max(s.peak.goroutines, m.goroutines)
+}
+
+// failed counts the lookups that failed.
+func (s *Soak) failed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failed := 0
+	for _, n := range s.errors {
+		failed += n
+	}
+	return failed
+}
+
+// progress is a line on how the soak test is going.
+func (s *Soak) progress(elapsed time.Duration) string {
+	failed := s.failed()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("[%s] heap %s, %d goroutines, %d lookups, %d errors",
+		elapsed.Round(time.Second), megabytes(s.last.heap), s.last.goroutines, s.fetches, failed)
+}
+
+// leaking reports if goroutines grew by more than a round's worth of workers since the first
+// round, which they wouldn't if everything that's started finishes.
+func (s *Soak) leaking(concurrency int) bool {
+	return s.last.goroutines > s.settled.goroutines+concurrency
+}
+
+// Report prints how memory and goroutines grew and what went wrong, most common first.
+func (s *Soak) Report(duration time.Duration) {
+	failed := s.failed()
+	percent := 0.0
+	if s.fetches > 0 {
+		percent = 100 * float64(failed) / float64(s.fetches)
+	}
+	fmt.Printf("soak: %s, %d lookups, %d errors (%.1f%%)\n", duration, s.fetches, failed, percent)
+	fmt.Printf("%-10s %10s %10s %10s\n", "", "settled", "end", "peak")
+	fmt.Printf("%-10s %10s %10s %10s\n", "heap", megabytes(s.settled.heap), megabytes(s.last.heap), megabytes(s.peak.heap))
+	fmt.Printf("%-10s %10d %10d %10d\n", "goroutines", s.settled.goroutines, s.last.goroutines, s.peak.goroutines)
+
+	kinds := []string{}
+	for kind := range s.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return s.errors[kinds[i]] > s.errors[kinds[j]] })
+	for _, kind := range kinds {
+		fmt.Printf("  %5d x %s\n", s.errors[kind], kind)
+	}
+}
+
+// megabytes writes a number of bytes in megabytes. ("3.1 MB")
+func megabytes(b uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+}
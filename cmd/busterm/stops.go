@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"gopkg.in/ukautz/clif.v1"
+)
+
+//go:generate go run naptan_gen.go -areas 220,228,320,329,370,450
+
+// naptanCSV is the NaPTAN stops list, cut down to the columns in Stop and gzipped. The copy in
+// the repository is empty, so busterm only has stops when go generate fetched Yorkshire's before
+// building, or a list was put in the assets directory.
+var naptanCSV = sync.OnceValues(func() ([]byte, error) {
+	return readAsset("naptan.csv.gz")
+})
+
+// Stop is a bus stop from NaPTAN.
+type Stop struct {
+	// ATCO is the stop's ATCO code. (450010687)
+	ATCO string
+	// Naptan is the stop's NapTAN code, the one on the flag. (45010687)
+	Naptan string
+	// Name is the stop's common name. ("City Square")
+	Name string
+	// Indicator tells stops with the same name apart. ("Stop K", "opp")
+	Indicator string
+	// Street is the street the stop is on.
+	Street string
+	// Bearing is the way buses leave the stop. ("NE")
+	Bearing string
+	// Locality is the town or district. ("Leeds City Centre")
+	Locality string
+	// Latitude and Longitude are where the stop is.
+	Latitude, Longitude float64
+}
+
+// Title names a stop the way a flag would. ("City Square (Stop K)")
+func (s Stop) Title() string {
+	if s.Indicator == "" {
+		return s.Name
+	}
+	return s.Name + " (" + s.Indicator + ")"
+}
+
+// errNoStops is what commands needing stops say when the NaPTAN stops list is empty.
+var errNoStops = errors.New("the NaPTAN stops list is empty, run `go generate ./cmd/busterm` before building or put one in the assets directory.")
+
+// checkStops returns errNoStops if the NaPTAN stops list is empty, so finding nothing in it
+// isn't mistaken for there being no such stop.
+func checkStops() error {
+	found := false
+	err := eachStop(func(Stop) bool {
+		found = true
+		return false
+	})
+	if err == nil && !found {
+		err = errNoStops
+	}
+	return err
+}
+
+// eachStop calls fn with every stop in the embedded NaPTAN list, until it returns false.
+func eachStop(fn func(Stop) bool) error {
+	data, err := naptanCSV()
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(gz)
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s := Stop{
+			ATCO:      record[columns["ATCOCode"]],
+			Naptan:    record[columns["NaptanCode"]],
+			Name:      record[columns["CommonName"]],
+			Indicator: record[columns["Indicator"]],
+			Street:    record[columns["Street"]],
+			Bearing:   record[columns["Bearing"]],
+			Locality:  record[columns["LocalityName"]],
+		}
+		s.Latitude, _ = strconv.ParseFloat(record[columns["Latitude"]], 64)
+		s.Longitude, _ = strconv.ParseFloat(record[columns["Longitude"]], 64)
+		if !fn(s) {
+			return nil
+		}
+	}
+}
+
+// stopCodes indexes every stop by its NapTAN and ATCO codes, read once the first time a stop is
+// looked up, so lookups don't read the whole list each time.
+var stopCodes = sync.OnceValues(func() (map[string]Stop, error) {
+	codes := map[string]Stop{}
+	err := eachStop(func(s Stop) bool {
+		if s.Naptan != "" {
+			codes[s.Naptan] = s
+		}
+		if s.ATCO != "" {
+			codes[s.ATCO] = s
+		}
+		return true
+	})
+	return codes, err
+})
+
+// findStop looks a stop up by its NapTAN or ATCO code.
+func findStop(code string) (Stop, bool) {
+	codes, err := stopCodes()
+	if err != nil {
+		return Stop{}, false
+	}
+	s, ok := codes[code]
+	return s, ok
+}
+
+// stopName names a stop for the board's heading, or is empty for stops NaPTAN doesn't have.
+func stopName(code string) string {
+	if s, ok := findStop(code); ok {
+		return s.Title()
+	}
+	return ""
+}
+
+// describeStop prints what NaPTAN knows about a stop, for `busterm stops`.
+func describeStop(c clif.Output, code string) error {
+	s, ok := findStop(code)
+	if !ok {
+		if err := checkStops(); err != nil {
+			return err
+		}
+		return errors.New("no stop " + code + " in NaPTAN.")
+	}
+	c.Printf("<headline>%s<reset>\n", s.Title())
+	c.Printf("Locality: %s\n", s.Locality)
+	c.Printf("Street:   %s\n", s.Street)
+	c.Printf("Bearing:  %s\n", s.Bearing)
+	c.Printf("NapTAN:   %s\n", s.Naptan)
+	c.Printf("ATCO:     %s\n", s.ATCO)
+	return nil
+}
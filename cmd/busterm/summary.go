@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// summaryBuses is how many buses a summary mentions.
+const summaryBuses = 3
+
+// summary says when the next buses are due in one sentence, for voice assistants and screen
+// readers. ("Next bus: 36 to Leeds in 4 minutes, then X84 in 11.")
+func summary(buses []Bus) string {
+	if len(buses) == 0 {
+		return "No buses are due at this stop."
+	}
+	first := buses[0]
+	sentence := fmt.Sprintf("Next bus: %s to %s %s", first.Service, first.To, spokenTime(first, true))
+	for _, bus := range buses[1:min(len(buses), summaryBuses)] {
+		sentence += fmt.Sprintf(", then %s %s", bus.Service, spokenTime(bus, false))
+	}
+	return sentence + "."
+}
+
+// spokenTime words a bus's time to be read out. The first time says minutes in full, the rest
+// leave them understood.
+func spokenTime(bus Bus, first bool) string {
+	switch {
+	case bus.Expected.IsZero():
+		return "at an unknown time"
+	case bus.Due:
+		return "due now"
+	case bus.ClockTime():
+		return "at " + bus.When()
+	case bus.Minutes == 1:
+		return "in 1 minute"
+	case first:
+		return fmt.Sprintf("in %d minutes", bus.Minutes)
+	}
+	return fmt.Sprintf("in %d", bus.Minutes)
+}
+
+// serveSummary sends the summary of a stop's board as plain text.
+func (s *Server) serveSummary(ctx context.Context, w http.ResponseWriter, code string, f Filter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	code = resolveAlias(code)
+	if err := checkCode(code); err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, "NapTAN code must be an 8 digit number.")
+		return
+	}
+
+	buses, err := s.lookup(ctx, code)
+	if err != nil {
+		if Retryable(err) {
+			w.Header().Set("Retry-After", "30")
+		}
+		status, _ := errorStatus(err)
+		w.WriteHeader(status)
+		fmt.Fprintln(w, "Bus times aren't available right now.")
+		return
+	}
+	w.WriteHeader(200)
+	fmt.Fprintln(w, summary(f.Apply(buses)))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tflAPI is the TfL Unified API.
+var tflAPI = "https://api.tfl.gov.uk"
+
+// TfL reads arrivals from the TfL Unified API, for London stops. Stops are ATCO codes
+// starting with 490. (490008660N)
+type TfL struct {
+	once sync.Once
+	// key is the app_key from `busterm auth set tfl`. TfL answers without one, just more slowly.
+	key string
+	// HTTPClient makes the requests, or is nil for the shared upstream client.
+	HTTPClient *http.Client
+}
+
+// String names the provider in reports.
+func (t *TfL) String() string {
+	return "tfl"
+}
+
+// Capabilities of TfL: predicted arrivals only.
+func (t *TfL) Capabilities() Capabilities {
+	return Capabilities{Realtime: true}
+}
+
+// CheckStop accepts ATCO codes.
+func (t *TfL) CheckStop(ref string) error {
+	return checkATCO(ref)
+}
+
+// tflArrival is the part of a TfL arrival prediction busterm uses.
+type tflArrival struct {
+	LineName        string    `json:"lineName"`
+	DestinationName string    `json:"destinationName"`
+	ExpectedArrival time.Time `json:"expectedArrival"`
+}
+
+// FetchDepartures asks TfL for the arrivals at a stop.
+func (t *TfL) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	// Look the key up once, the first time it's needed.
+	t.once.Do(func() {
+		t.key, _ = credential("tfl")
+	})
+	endpoint := tflAPI + "/StopPoint/" + url.PathEscape(ref) + "/Arrivals"
+	if t.key != "" {
+		endpoint += "?app_key=" + url.QueryEscape(t.key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return []Bus{}, err
+	}
+	res, err := orUpstream(t.HTTPClient).Do(req)
+	if err != nil {
+		return []Bus{}, fmt.Errorf("%w: %s", ErrUpstreamDown, scrub(err.Error(), t.key))
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 429:
+		return []Bus{}, fmt.Errorf("%w: TfL says %s", ErrRateLimited, res.Status)
+	case res.StatusCode == 404:
+		return []Bus{}, fmt.Errorf("%w: TfL doesn't know stop %s", ErrInvalidStop, ref)
+	case res.StatusCode != 200:
+		return []Bus{}, fmt.Errorf("%w: status != 200: status:%s", ErrUpstreamDown, res.Status)
+	}
+
+	var arrivals []tflArrival
+	if err := json.NewDecoder(res.Body).Decode(&arrivals); err != nil {
+		return []Bus{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	now := clock()
+	departures := []departure{}
+	for _, a := range arrivals {
+		bus := Bus{Service: a.LineName, To: a.DestinationName}
+		bus.DepartsAt(a.ExpectedArrival, false, now)
+		departures = append(departures, departure{bus, a.ExpectedArrival})
+	}
+	return soonestFirst(departures), nil
+}
+
+// London sends London stops to TfL and the rest to another provider, so London users
+// don't get an empty board from a site that doesn't cover them.
+type London struct {
+	// Elsewhere is the provider for stops outside London.
+	Elsewhere Provider
+	tfl       *TfL
+}
+
+// withLondon routes London stops of a provider to TfL.
+func withLondon(elsewhere Provider) London {
+	return London{Elsewhere: elsewhere, tfl: &TfL{HTTPClient: upstreamClient()}}
+}
+
+// isLondon tells London stops apart: their ATCO codes start with 490, and are longer
+// than the 8 digit NapTAN codes ACIS takes.
+func isLondon(ref string) bool {
+	return strings.HasPrefix(ref, "490") && len(ref) > 8 && checkATCO(ref) == nil
+}
+
+// String names the provider for stops outside London in reports.
+func (l London) String() string {
+	return fmt.Sprint(l.Elsewhere)
+}
+
+// Capabilities of the other provider, and TfL's for London stops.
+func (l London) Capabilities() Capabilities {
+	return capabilitiesOf(l.Elsewhere).Or(capabilitiesOf(l.tfl))
+}
+
+// CheckStop accepts London ATCO codes as well as the other provider's stops.
+func (l London) CheckStop(ref string) error {
+	if isLondon(ref) {
+		return nil
+	}
+	if p, ok := l.Elsewhere.(stopChecker); ok {
+		return p.CheckStop(ref)
+	}
+	return checkNaptan(ref)
+}
+
+// FetchDepartures fetches London stops from TfL, and the rest from the other provider.
+func (l London) FetchDepartures(ctx context.Context, ref string) ([]Bus, error) {
+	if isLondon(ref) {
+		return l.tfl.FetchDepartures(ctx, ref)
+	}
+	return l.Elsewhere.FetchDepartures(ctx, ref)
+}
+
+// Preconnect gets the other provider's connection ready, if it can.
+func (l London) Preconnect() error {
+	if p, ok := l.Elsewhere.(preconnecter); ok {
+		return p.Preconnect()
+	}
+	return errors.ErrUnsupported
+}
+
+// scrub hides a secret in an error, as the URL in it carries the key. Without a key there's
+// nothing to hide.
+func scrub(msg, secret string) string {
+	if secret == "" {
+		return msg
+	}
+	return strings.ReplaceAll(msg, secret, "***")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// upstreamTransport is shared by every fetch, so connections to the upstream site are kept and reused
+// between refreshes. HTTP/2 is used when the site offers it over TLS, where stopping a read early
+// only resets the stream rather than the whole connection.
+var upstreamTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2: true,
+	// Enough for the API's fetches of uncached stops, or bench and soak at their default
+	// concurrency, to keep their connections between requests.
+	MaxIdleConns:        64,
+	MaxIdleConnsPerHost: 16,
+	// Longer than the refresh interval, so watch mode keeps its connection.
+	IdleConnTimeout:       2 * time.Minute,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// upstreamHTTP is the one client every source and lookup fetches with, set up once and shared by
+// every goroutine. Its timeout is how long a fetch may take. (0 = no limit, --fetch-timeout)
+var upstreamHTTP = &http.Client{Transport: upstreamTransport, Timeout: 15 * time.Second}
+
+// upstreamClient returns the shared client for the upstream site.
+func upstreamClient() *http.Client {
+	return upstreamHTTP
+}
+
+// orUpstream returns a provider's own client, or the shared one when it has none.
+func orUpstream(client *http.Client) *http.Client {
+	if client == nil {
+		return upstreamClient()
+	}
+	return client
+}
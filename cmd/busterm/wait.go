@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errTimeout is returned when the bus we're waiting for doesn't turn up in time.
+var errTimeout = errors.New("timed out waiting for the bus.")
+
+// waitFor polls a stop until a bus passing the filter is on the board, or is due with untilDue.
+// It gives up after timeout, unless the timeout is 0.
+func waitFor(ref string, f Filter, untilDue bool, timeout time.Duration, every time.Duration) (Bus, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = clock().Add(timeout)
+	}
+
+	for {
+		buses, err := getBuses(context.Background(), ref)
+		// Outages don't end the wait, the next poll may work.
+		if err != nil && !Retryable(err) {
+			return Bus{}, err
+		}
+		for _, bus := range f.Apply(buses) {
+			if !untilDue || bus.Due {
+				return bus, nil
+			}
+		}
+
+		// Don't sleep past the deadline.
+		wait := every
+		if !deadline.IsZero() {
+			left := deadline.Sub(clock())
+			if left <= 0 {
+				return Bus{}, errTimeout
+			}
+			if left < wait {
+				wait = left
+			}
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Warm keeps the last known board of the configured stops across restarts,
+// so there's something to show while fresh data is fetched.
+type Warm struct {
+	mu     sync.Mutex
+	path   string
+	stops  map[string]bool
+	boards map[string]cached
+	// fresh is set for stops fetched since startup, which no longer need the old board.
+	fresh map[string]bool
+	// fetching is set for stops being fetched in the background.
+	fetching map[string]bool
+}
+
+var (
+	// warm is the warm cache, if enabled.
+	warm *Warm
+
+	// offline is set by --offline. Only saved boards are shown and the network is never used.
+	offline bool
+)
+
+// warmPath returns the default location of the warm cache file.
+func warmPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "busterm", "warm.json")
+}
+
+// openWarm loads the warm cache for the configured stops and any others given,
+// and saves it again when busterm is stopped.
+func (config Config) openWarm(stops ...string) error {
+	path := config.WarmFile
+	if path == "" {
+		path = warmPath()
+	}
+	if path == "off" || path == "" {
+		return nil
+	}
+	w, err := LoadWarm(path, append(config.Stops, stops...))
+	if err != nil {
+		return err
+	}
+	warm = w
+	saveOnExit()
+	return nil
+}
+
+// LoadWarm loads the boards saved at the last shutdown. A missing file is fine.
+func LoadWarm(path string, stops []string) (*Warm, error) {
+	w := &Warm{path: path, stops: map[string]bool{}, boards: map[string]cached{}, fresh: map[string]bool{}, fetching: map[string]bool{}}
+	for _, ref := range stops {
+		w.stops[ref] = true
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &w.boards); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetStops changes which stops are kept, when the config file is reloaded.
+func (w *Warm) SetStops(stops ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stops = map[string]bool{}
+	for _, ref := range stops {
+		w.stops[ref] = true
+	}
+}
+
+// Remember keeps a freshly fetched board of a configured stop.
+func (w *Warm) Remember(ref string, buses []Bus) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stops[ref] {
+		return
+	}
+	w.boards[ref] = cached{Buses: copyBuses(buses), Fetched: clock()}
+	w.fresh[ref] = true
+}
+
+// Stale returns the board saved at the last shutdown, marked stale, until the stop has been fetched again.
+func (w *Warm) Stale(ref string) ([]Bus, bool) {
+	w.mu.Lock()
+	fresh := w.fresh[ref]
+	w.mu.Unlock()
+	if fresh {
+		return nil, false
+	}
+	buses, fetched, ok := w.Saved(ref)
+	if !ok {
+		return nil, false
+	}
+	return markStale(buses, fetched), true
+}
+
+// Saved returns the last board kept for a stop however old it is, and when it was fetched.
+func (w *Warm) Saved(ref string) ([]Bus, time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.boards[ref]
+	return copyBuses(entry.Buses), entry.Fetched, ok
+}
+
+// saved returns the newest board kept for a stop in the warm file or the cache, for offline mode.
+func saved(ref string) ([]Bus, error) {
+	var buses []Bus
+	var fetched time.Time
+	if warm != nil {
+		if b, at, ok := warm.Saved(ref); ok {
+			buses, fetched = b, at
+		}
+	}
+	if b, at, ok := cache.Last(ref); ok && at.After(fetched) {
+		buses, fetched = b, at
+	}
+	if fetched.IsZero() {
+		return []Bus{}, errors.New("no saved board for " + ref + " while offline.")
+	}
+	return markStale(buses, fetched), nil
+}
+
+// isStale reports if a board is a saved one rather than just fetched.
+func isStale(buses []Bus) bool {
+	return len(buses) > 0 && buses[0].Stale
+}
+
+// markStale marks every bus on a saved board as stale, with when it was fetched.
+// Times like "5 mins" were counted from the fetch, so they're shown as clock times.
+func markStale(buses []Bus, fetched time.Time) []Bus {
+	for i := range buses {
+		buses[i].Stale = true
+		buses[i].Fetched = fetched
+		// Boards saved before buses had times of their own only have the board's text.
+		if buses[i].Expected.IsZero() {
+			buses[i].ReadTime(buses[i].Time, fetched)
+		}
+		buses[i].Time = buses[i].When()
+	}
+	return buses
+}
+
+// staleness labels how old a saved bus is. ("stale, 14m old")
+func staleness(fetched time.Time) string {
+	if fetched.IsZero() {
+		return "stale"
+	}
+	age := clock().Sub(fetched)
+	switch {
+	case age < time.Minute:
+		return "stale, just now"
+	case age < time.Hour:
+		return fmt.Sprintf("stale, %dm old", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("stale, %dh%02dm old", int(age.Hours()), int(age.Minutes())%60)
+	}
+	return fmt.Sprintf("stale, %dd old", int(age.Hours()/24))
+}
+
+// claim marks a stop as being fetched in the background. It's false if it already is.
+func (w *Warm) claim(ref string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fetching[ref] {
+		return false
+	}
+	w.fetching[ref] = true
+	return true
+}
+
+// release marks a stop as no longer being fetched.
+func (w *Warm) release(ref string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.fetching, ref)
+}
+
+// Save writes the boards of the configured stops to disk.
+func (w *Warm) Save() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	boards := map[string]cached{}
+	for ref, entry := range w.boards {
+		if w.stops[ref] {
+			boards[ref] = entry
+		}
+	}
+	data, err := json.Marshal(boards)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash can't leave half a file behind.
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// saveOnExit saves the warm cache when busterm is interrupted or terminated.
+func saveOnExit() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if warm != nil {
+			if err := warm.Save(); err != nil {
+				log.Println("warm:", err)
+			}
+		}
+		os.Exit(0)
+	}()
+}
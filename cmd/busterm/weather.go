@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// weatherAPI is the Open-Meteo forecast API, which needs no key.
+var weatherAPI = "https://api.open-meteo.com/v1/forecast"
+
+// weatherEvery is how long a stop's weather is kept before asking again.
+var weatherEvery = 15 * time.Minute
+
+// Weather warns of weather that tends to delay buses (ice, heavy rain, snow, storms and gales)
+// at the stops on the board.
+type Weather struct {
+	mu     sync.Mutex
+	checks map[string]weatherCheck
+}
+
+// weatherCheck is the last warning for a stop and when it was worked out.
+type weatherCheck struct {
+	warning string
+	at      time.Time
+}
+
+// weather is nil unless the config file turns weather warnings on.
+var weather *Weather
+
+// NewWeather makes an empty set of weather warnings.
+func NewWeather() *Weather {
+	return &Weather{checks: map[string]weatherCheck{}}
+}
+
+// Warning returns the weather warning for a stop, or "" when the weather is fine or unknown.
+// Only stops NaPTAN knows the location of can be checked.
+func (w *Weather) Warning(ref string) string {
+	w.mu.Lock()
+	check, ok := w.checks[ref]
+	w.mu.Unlock()
+	if ok && clock().Sub(check.at) < weatherEvery {
+		return check.warning
+	}
+
+	stop, ok := findStop(ref)
+	if !ok || (stop.Latitude == 0 && stop.Longitude == 0) {
+		return ""
+	}
+	warning, err := weatherWarning(stop.Latitude, stop.Longitude)
+	if err != nil {
+		// Keep the last warning rather than going quiet while the forecast is down, and don't ask
+		// again until it's due, so every board doesn't wait on Open-Meteo while it's down.
+		warning = check.warning
+	}
+	w.mu.Lock()
+	w.checks[ref] = weatherCheck{warning, clock()}
+	w.mu.Unlock()
+	return warning
+}
+
+// weatherWarning asks Open-Meteo for the current weather at a place and words a warning for it.
+func weatherWarning(lat, lon float64) (string, error) {
+	url := fmt.Sprintf("%s?latitude=%.4f&longitude=%.4f&current=temperature_2m,precipitation,weather_code,wind_gusts_10m&wind_speed_unit=mph", weatherAPI, lat, lon)
+	res, err := upstreamClient().Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("Open-Meteo says %s", res.Status)
+	}
+
+	var body struct {
+		Current struct {
+			Temperature   float64 `json:"temperature_2m"`
+			Precipitation float64 `json:"precipitation"`
+			Code          int     `json:"weather_code"`
+			Gusts         float64 `json:"wind_gusts_10m"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	now := body.Current
+
+	// Weather codes are WMO codes. (https://open-meteo.com/en/docs)
+	switch {
+	case now.Code == 56 || now.Code == 57 || now.Code == 66 || now.Code == 67,
+		now.Temperature <= 0 && now.Precipitation > 0:
+		return "Ice near the stop, expect delays.", nil
+	case now.Code == 75 || now.Code == 86:
+		return "Heavy snow near the stop, expect delays.", nil
+	case now.Code >= 95:
+		return "Thunderstorms near the stop, expect delays.", nil
+	case now.Code == 65 || now.Code == 82:
+		return "Heavy rain near the stop, expect delays.", nil
+	case now.Gusts >= 50:
+		return fmt.Sprintf("Gusts of %.0f mph near the stop, expect delays.", now.Gusts), nil
+	}
+	return "", nil
+}
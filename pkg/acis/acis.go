@@ -0,0 +1,218 @@
+// Package acis reads departures from ACIS Connect sites, such as yorkshire.acisconnect.com,
+// by scraping their text departure pages.
+//
+//	buses, err := acis.Client{}.Departures(ctx, "45010687")
+//
+// Failures wrap the kinds in the bus package, so errors.Is(err, bus.ErrUpstreamDown) tells
+// a site that's down from a page that can't be read.
+package acis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/return/busterm/pkg/bus"
+	"golang.org/x/net/html"
+)
+
+// DefaultURL is the departure page of West Yorkshire's ACIS Connect site.
+const DefaultURL = "http://yorkshire.acisconnect.com/Text/WebDisplay.aspx"
+
+// DefaultUserAgent is sent with every request unless the Client says otherwise, as the site
+// turns away ones it doesn't recognise.
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 6.2; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/30.0.1599.17 Safari/537.36"
+
+// Client fetches departures from an ACIS Connect site. The zero Client fetches from DefaultURL
+// with http.DefaultClient.
+type Client struct {
+	// BaseURL is the departure page. (http://yorkshire.acisconnect.com/Text/WebDisplay.aspx)
+	BaseURL string
+	// HTTPClient makes the requests.
+	HTTPClient *http.Client
+	// UserAgent is sent with the requests. (DefaultUserAgent)
+	UserAgent string
+	// Now is when countdowns ("5 mins") count from. (time.Now)
+	Now func() time.Time
+}
+
+// Departures fetches the buses leaving a stop, by its 8 digit NapTAN code, soonest first as
+// the site lists them. A stop with nothing on its board is bus.ErrNoDepartures.
+func (c Client) Departures(ctx context.Context, stopRef string) ([]bus.Bus, error) {
+	baseURL, client, ua, now := c.BaseURL, c.HTTPClient, c.UserAgent, c.Now
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"?stopRef="+stopRef, nil)
+	if err != nil {
+		return []bus.Bus{}, err
+	}
+
+	// Add user-agent for the request.
+	req.Header.Add("User-Agent", ua)
+
+	res, perr := client.Do(req)
+	if perr != nil {
+		return []bus.Bus{}, bus.Timeout(fmt.Errorf("%w: %w", bus.ErrUpstreamDown, perr))
+	}
+
+	// Close response body. Anything after the table is never downloaded.
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 429:
+		return []bus.Bus{}, fmt.Errorf("%w: the upstream site says %s", bus.ErrRateLimited, res.Status)
+	case res.StatusCode != 200:
+		return []bus.Bus{}, fmt.Errorf("%w: status != 200: status:%s", bus.ErrUpstreamDown, res.Status)
+	}
+
+	// Parse the document as it arrives. The site may stall part way through.
+	buses, err := Parse(res.Body, now())
+	return buses, bus.Timeout(err)
+}
+
+// Parse reads the departures table from a HTML document and returns a collection of Buses,
+// with countdowns counted from now.
+//
+// The document is read as a stream and reading stops at the end of the table, so the rest of
+// the page never has to be downloaded. (handy on metered connections)
+//
+// Columns are found by their headings, so it doesn't matter what order ACIS puts them in. Rows
+// that don't fill the columns are skipped. A table with no departures is bus.ErrNoDepartures,
+// and one whose headings aren't recognised is bus.ErrLayoutChanged.
+func Parse(r io.Reader, now time.Time) ([]bus.Bus, error) {
+
+	// Create an array of Bus structs.
+	buses := []bus.Bus{}
+
+	// Walk through the tags one at a time, keeping track of the current row and cell.
+	z := html.NewTokenizer(r)
+	var columns map[string]int
+	var row []string
+	var text string
+	inRow, inCell, seenTable := false, false, false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			switch {
+			case z.Err() != io.EOF:
+				return []bus.Bus{}, fmt.Errorf("%w: %w", bus.ErrParse, z.Err())
+			case !seenTable:
+				// Whatever this page is, it isn't a departures board.
+				return []bus.Bus{}, fmt.Errorf("%w: no departures table on the page", bus.ErrParse)
+			}
+			// The document ended before the table did.
+			return departures(buses)
+
+		case html.TextToken:
+			if inCell {
+				text += string(z.Text())
+			}
+
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "table":
+				seenTable = true
+			case "tr":
+				row, inRow = []string{}, true
+			case "td", "th":
+				inCell, text = inRow, ""
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "td", "th":
+				if inCell {
+					row = append(row, strings.TrimSpace(text))
+				}
+				inCell = false
+			case "tr":
+				if !inRow {
+					break
+				}
+				inRow = false
+				// The first row is the headings.
+				if columns == nil {
+					var err error
+					if columns, err = headings(row); err != nil {
+						return []bus.Bus{}, err
+					}
+					break
+				}
+				if b, ok := rowBus(row, columns, now); ok {
+					buses = append(buses, b)
+				}
+			case "table":
+				// That's all the departures, don't read any further.
+				return departures(buses)
+			}
+		}
+	}
+}
+
+// headings finds the columns of the departures table by their headings. ("Service", "To", "Time"
+// and "Low Floor") The service, destination and time must be there; low floor is optional.
+func headings(row []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, heading := range row {
+		switch strings.ToLower(heading) {
+		case "service", "bus", "route":
+			columns["service"] = i
+		case "to", "destination":
+			columns["to"] = i
+		case "time", "due", "departs":
+			columns["time"] = i
+		case "low floor", "low-floor":
+			columns["low floor"] = i
+		}
+	}
+	for _, column := range []string{"service", "to", "time"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: no %s column in %q", bus.ErrLayoutChanged, column, row)
+		}
+	}
+	return columns, nil
+}
+
+// rowBus reads a bus from a row of the departures table. Rows missing cells or a service aren't buses.
+func rowBus(row []string, columns map[string]int, now time.Time) (bus.Bus, bool) {
+	for _, i := range columns {
+		if i >= len(row) {
+			return bus.Bus{}, false
+		}
+	}
+	b := bus.Bus{Service: row[columns["service"]], To: row[columns["to"]]}
+	if b.Service == "" {
+		return bus.Bus{}, false
+	}
+	b.ReadTime(row[columns["time"]], now)
+	if i, ok := columns["low floor"]; ok {
+		// Low floor buses are small ones, the rest are taken to be double deckers.
+		b.LowFloor = row[i] == "Yes"
+		b.DoubleDecker = !b.LowFloor
+	}
+	return b, true
+}
+
+// departures returns the buses read from the table, or bus.ErrNoDepartures when there are none.
+func departures(buses []bus.Bus) ([]bus.Bus, error) {
+	if len(buses) == 0 {
+		return []bus.Bus{}, bus.ErrNoDepartures
+	}
+	return buses, nil
+}
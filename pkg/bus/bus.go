@@ -0,0 +1,121 @@
+// Package bus holds the departures busterm shows, as read from any source, and the kinds of
+// failure fetching them can end in.
+//
+// A departure's time is kept twice: Time is the text shown on a board ("Due", "5 mins" or
+// "14:32"), and Expected, Minutes, Due and Scheduled say the same thing for programs.
+// DepartsAt sets them all from a point in time and ReadTime from a board's text.
+package bus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bus is a departure from a stop, whichever source it came from.
+type Bus struct {
+	Service      string    `json:"bus"`
+	To           string    `json:"to"`
+	Time         string    `json:"time"`
+	Expected     time.Time `json:"expected,omitzero"`
+	Minutes      int       `json:"minutes"`
+	Due          bool      `json:"due"`
+	Scheduled    bool      `json:"scheduled"`
+	LowFloor     bool      `json:"low_floor"`
+	DoubleDecker bool      `json:"double_decker"` // Deprecated: the opposite of LowFloor where the source says, use LowFloor.
+	Confidence   string    `json:"confidence,omitempty"`
+	Stale        bool      `json:"stale,omitempty"`
+	Fetched      time.Time `json:"fetched,omitzero"`
+	Operator     string    `json:"operator,omitempty"`
+}
+
+// String converts a Bus into a string representable format.
+func (bus Bus) String() string {
+	switch {
+	case bus.ClockTime():
+		return fmt.Sprintf("Bus %s going to %s @ %s", bus.Service, bus.To, bus.When())
+	case bus.Due:
+		return fmt.Sprintf("Bus %s going to %s is %s", bus.Service, bus.To, bus.When())
+	}
+	return fmt.Sprintf("Bus %s going to %s in %s", bus.Service, bus.To, bus.When())
+}
+
+// DepartsAt sets when a bus leaves and its time on the board. Scheduled is set for times from
+// the timetable rather than live estimates.
+func (bus *Bus) DepartsAt(at time.Time, scheduled bool, now time.Time) {
+	bus.Expected = at.Truncate(time.Second)
+	bus.Minutes = max(int(math.Round(at.Sub(now).Minutes())), 0)
+	bus.Due = bus.Minutes == 0
+	bus.Scheduled = scheduled
+	bus.Time = bus.When()
+}
+
+// ReadTime sets when a bus leaves from a board's "Due", "5 mins" or "14:32". Boards only count
+// down live estimates, so clock times are timetabled ones. Times that can't be read are kept as
+// they are, without the rest.
+func (bus *Bus) ReadTime(timestring string, now time.Time) {
+	bus.Time = timestring
+	if at, ok := ExpectedAt(timestring, now); ok {
+		bus.DepartsAt(at, strings.Contains(timestring, ":"), now)
+	}
+}
+
+// When writes a bus's time the way ACIS does: "Due", minutes for the next 20 minutes of live
+// estimates, then the clock time. Saved boards' countdowns are long out of date, so they're
+// shown on the clock too.
+func (bus Bus) When() string {
+	switch {
+	case bus.Expected.IsZero():
+		return bus.Time
+	case bus.ClockTime():
+		return bus.Expected.Local().Format("15:04")
+	case bus.Due:
+		return "Due"
+	case bus.Minutes == 1:
+		return "1 min"
+	}
+	return fmt.Sprintf("%d mins", bus.Minutes)
+}
+
+// ClockTime is set when a bus's time is shown on the clock rather than counted down.
+func (bus Bus) ClockTime() bool {
+	return !bus.Expected.IsZero() && (bus.Stale || !bus.Due && (bus.Scheduled || bus.Minutes > 20))
+}
+
+// ExpectedAt converts a bus time ("Due", "5 mins" or "14:32") into a point in time, counting
+// from now. Clock times before the last hour roll over to tomorrow.
+func ExpectedAt(timestring string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(timestring)
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+	tt := fields[0]
+
+	// The bus is at the stop.
+	if tt == "Due" {
+		return now, true
+	}
+
+	// The time is in minutes.
+	if !strings.Contains(tt, ":") {
+		minutes, err := strconv.Atoi(tt)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(minutes) * time.Minute), true
+	}
+
+	// The time is a clock time, which may roll over past midnight.
+	clock, err := time.Parse("15:04", tt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	y, m, d := now.Date()
+	eta := time.Date(y, m, d, clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if eta.Before(now.Add(-time.Hour)) {
+		eta = eta.AddDate(0, 0, 1)
+	}
+	return eta, true
+}
@@ -0,0 +1,45 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Kinds of failure busterm tells apart. Errors wrap one of these, so callers can
+// check them with errors.Is and still print the details.
+var (
+	// ErrInvalidStop is returned for stop codes that aren't 8 digit NapTAN codes.
+	ErrInvalidStop = errors.New("invalid stop")
+	// ErrUpstreamDown is returned when the upstream site can't be reached or answers with an error.
+	ErrUpstreamDown = errors.New("upstream down")
+	// ErrTimeout is returned when the upstream site takes too long to answer.
+	ErrTimeout = errors.New("timed out")
+	// ErrParse is returned when the upstream answers with something that isn't a departures page.
+	ErrParse = errors.New("unreadable departures")
+	// ErrRateLimited is returned when busterm or the upstream site won't fetch right now.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrLayoutChanged is returned when the departures table isn't laid out the way busterm knows.
+	// It's a kind of ErrParse.
+	ErrLayoutChanged = fmt.Errorf("%w: the departures table has changed layout", ErrParse)
+	// ErrNoDepartures is returned when a stop has no buses on its board. It's an answer rather
+	// than a failure, shown as an empty board.
+	ErrNoDepartures = errors.New("no departures")
+)
+
+// Timeout marks err as ErrTimeout when it's a network timeout or a passed deadline, so slow
+// sources can be told from ones that are down. Other errors are returned as they are.
+func Timeout(err error) error {
+	var netErr net.Error
+	timedOut := errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+	if !timedOut || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
+
+// Retryable reports if trying again later may work. (the upstream is down, slow or rate limited)
+func Retryable(err error) bool {
+	return errors.Is(err, ErrUpstreamDown) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrRateLimited)
+}
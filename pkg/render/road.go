@@ -0,0 +1,52 @@
+package render
+
+import (
+	"strings"
+	"time"
+
+	"github.com/return/busterm/pkg/bus"
+)
+
+// Road draws how far away a bus is as a road leading up to the stop.
+//
+// The road is Length segments long and covers Window, so each segment is Window/Length. With
+// 12 segments over an hour every "_" is 5 minutes and "🚏__🚌__________" is a bus 10 minutes
+// away. Buses beyond the window wait at the far end.
+type Road struct {
+	// Bus, DoubleDecker and Stop are the glyphs drawn. ("🚌", "🚐" and "🚏")
+	Bus          string
+	DoubleDecker string
+	Stop         string
+	// Length is how many segments the road has.
+	Length int
+	// Window is how far ahead the road reaches.
+	Window time.Duration
+}
+
+// Draw draws the road for a bus, counting its wait from now.
+func (r Road) Draw(b bus.Bus, now time.Time) string {
+	glyph := r.Bus
+	if b.DoubleDecker {
+		glyph = r.DoubleDecker
+	}
+
+	// Work out how far away the bus is. Buses without a time wait at the far end.
+	wait := r.Window
+	if !b.Expected.IsZero() {
+		// Boards count in whole minutes.
+		wait = b.Expected.Sub(now).Round(time.Minute)
+	}
+
+	// Scale the wait onto the road, keeping the bus between the stop and the end.
+	roads := 0
+	if r.Window > 0 {
+		roads = int(wait * time.Duration(r.Length) / r.Window)
+	}
+	if roads < 0 {
+		roads = 0
+	}
+	if roads > r.Length {
+		roads = r.Length
+	}
+	return r.Stop + strings.Repeat("_", roads) + glyph + strings.Repeat("_", r.Length-roads)
+}
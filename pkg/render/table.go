@@ -0,0 +1,150 @@
+// Package render lays out departures for the terminal: tables measured by how wide their cells
+// look rather than their length in bytes, and roads drawing how far away each bus is.
+package render
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// markup matches clif style tags, which take no room on screen. ("<warn>", "<reset>")
+var markup = regexp.MustCompile(`<[a-z_:]+>`)
+
+// displayWidth is how many terminal columns a cell takes. Wide characters (emoji, CJK) take two,
+// direction marks take none.
+func displayWidth(s string) int {
+	return runewidth.StringWidth(strings.Map(dropBidi, markup.ReplaceAllString(s, "")))
+}
+
+// dropBidi drops the invisible direction marks and isolates. (for strings.Map)
+func dropBidi(r rune) rune {
+	switch r {
+	case '\u200e', '\u200f', '\u2066', '\u2067', '\u2068', '\u2069':
+		return -1
+	}
+	return r
+}
+
+// isolate wraps right-to-left text (Hebrew, Arabic) in a first strong isolate, so the terminal
+// reorders it within its own cell instead of dragging the column separators and padding along.
+func isolate(s string) string {
+	for _, r := range s {
+		if p, _ := bidi.LookupRune(r); p.Class() == bidi.R || p.Class() == bidi.AL {
+			return "\u2068" + s + "\u2069"
+		}
+	}
+	return s
+}
+
+// pad fills a cell with spaces up to a display width.
+func pad(s string, width int) string {
+	if w := displayWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// truncate cuts a cell down to a display width, ending it with "…". Markup is kept,
+// and closed with <reset> if the cut drops it.
+func truncate(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	used := 0
+	styled := false
+	rest := s
+	for rest != "" {
+		// Copy markup as it is.
+		if loc := markup.FindStringIndex(rest); loc != nil && loc[0] == 0 {
+			tag := rest[:loc[1]]
+			styled = tag != "<reset>"
+			b.WriteString(tag)
+			rest = rest[loc[1]:]
+			continue
+		}
+		// Invalid bytes come out as U+FFFD, one byte at a time.
+		r, size := utf8.DecodeRuneInString(rest)
+		w := runewidth.RuneWidth(r)
+		if dropBidi(r) == -1 {
+			w = 0
+		}
+		// Leave room for the ellipsis.
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+		rest = rest[size:]
+	}
+	b.WriteString("…")
+	if styled {
+		b.WriteString("<reset>")
+	}
+	return b.String()
+}
+
+// Columns lays out a table with a header, measuring every cell by its display width
+// rather than its length in bytes. With maxWidth > 0 the widest columns are truncated to fit.
+// Cells may hold clif style markup ("<warn>"), which takes no room.
+func Columns(headers []string, rows [][]string, maxWidth int) string {
+	widths := make([]int, len(headers))
+	for _, row := range append([][]string{headers}, rows...) {
+		for i, cell := range row {
+			if i < len(widths) && displayWidth(cell) > widths[i] {
+				widths[i] = displayWidth(cell)
+			}
+		}
+	}
+
+	// Take from the widest column until the table fits. (" a │ b " has 3 columns of padding per gap)
+	if maxWidth > 0 {
+		total := func() int {
+			sum := 3*len(widths) - 1
+			for _, w := range widths {
+				sum += w
+			}
+			return sum
+		}
+		for total() > maxWidth {
+			widest := 0
+			for i, w := range widths {
+				if w > widths[widest] {
+					widest = i
+				}
+			}
+			if widths[widest] <= 3 {
+				break
+			}
+			widths[widest]--
+		}
+	}
+
+	line := func(row []string) string {
+		cells := make([]string, len(widths))
+		for i := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			cells[i] = pad(isolate(truncate(cell, widths[i])), widths[i])
+		}
+		return " " + strings.Join(cells, " │ ") + " "
+	}
+
+	var b strings.Builder
+	b.WriteString(line(headers) + "\n")
+	rules := make([]string, len(widths))
+	for i, w := range widths {
+		rules[i] = strings.Repeat("─", w)
+	}
+	b.WriteString("─" + strings.Join(rules, "─┼─") + "─\n")
+	for _, row := range rows {
+		b.WriteString(line(row) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cell  string
+		width int
+		want  string
+	}{
+		{"fits", "Leeds", 5, "Leeds"},
+		{"ascii", "Leeds Bus Station", 6, "Leeds…"},
+		{"invalid byte", "Caf\xe9 Street", 8, "Caf� St…"},
+		{"only invalid bytes", "\xe9\xe9\xe9\xe9", 3, "��…"},
+		{"wide", "東京駅前", 5, "東京…"},
+		{"wide cut short", "東京駅前", 4, "東…"},
+		{"direction marks", "a\u200fbcdef", 4, "a\u200fbc…"},
+		{"markup", "<warn>Leeds Bus Station", 6, "<warn>Leeds…<reset>"},
+		{"markup closed", "<warn>Leeds<reset> Bus Station", 8, "<warn>Leeds<reset> B…"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := truncate(test.cell, test.width)
+			if got != test.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", test.cell, test.width, got, test.want)
+			}
+			if w := displayWidth(got); w > test.width {
+				t.Errorf("truncate(%q, %d) is %d wide", test.cell, test.width, w)
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     [][]string
+		maxWidth int
+	}{
+		{"invalid bytes", [][]string{{"36", "\xe9\xe9\xe9\xe9"}}, 11},
+		{"invalid byte mid word", [][]string{{"36", "Caf\xe9 Street"}}, 12},
+		{"wide", [][]string{{"36", "東京駅前行き"}}, 11},
+		{"right to left", [][]string{{"36", "תחנה מרכזית"}}, 11},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table := Columns([]string{"No.", "To"}, test.rows, test.maxWidth)
+			for _, line := range strings.Split(strings.TrimSuffix(table, "\n"), "\n") {
+				if w := displayWidth(line); w > test.maxWidth {
+					t.Errorf("line %q is %d wide, want at most %d", line, w, test.maxWidth)
+				}
+			}
+		})
+	}
+}